@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	gojsonnet "github.com/git-justanotherone/jsonnet-custodian/cmd/internal/upstream/go-jsonnet"
 )
@@ -18,6 +20,9 @@ func cmdMainUsage(o io.Writer) {
 	fmt.Fprintln(o)
 	fmt.Fprintln(o, "The commands are:")
 	fmt.Fprintln(o, "    mod        Module management commands")
+	fmt.Fprintln(o, "    work       Workspace management commands")
+	fmt.Fprintln(o, "    serve      Run a module proxy server")
+	fmt.Fprintln(o, "    sumdb      Run a checksum database server")
 	fmt.Fprintln(o, "    jsonnet    Run the jsonnet-extended interpreter. (like jsonnet but with extensions)")
 	fmt.Fprintln(o)
 	fmt.Fprintln(o, "Use \"custodian <command> -h\" for more information about a command.")
@@ -44,6 +49,21 @@ func cmdMain(o io.Writer, args []string) error {
 		if err != nil {
 			panic(err)
 		}
+	case "work":
+		err := cmdWorkMain(o, subArgs)
+		if err != nil {
+			panic(err)
+		}
+	case "serve":
+		err := cmdServeMain(o, subArgs)
+		if err != nil {
+			panic(err)
+		}
+	case "sumdb":
+		err := cmdSumdbMain(o, subArgs)
+		if err != nil {
+			panic(err)
+		}
 	case "jsonnet":
 		err := gojsonnet.CmdJsonnetMain(subArgs)
 		if err != nil {
@@ -57,7 +77,24 @@ func cmdMain(o io.Writer, args []string) error {
 	return nil
 }
 
+// JSONNET_COMMAND is the name under which the binary behaves as the jsonnet
+// command, e.g. through a symlink, for tools running jsonnet.
+const JSONNET_COMMAND = "jsonnet"
+
+// isJsonnetCommand reports whether the binary was invoked as the jsonnet
+// command.
+func isJsonnetCommand(arg0 string) bool {
+	return strings.TrimSuffix(filepath.Base(arg0), ".exe") == JSONNET_COMMAND
+}
+
 func main() {
+	if isJsonnetCommand(os.Args[0]) {
+		if err := gojsonnet.CmdJsonnetMain(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: "+err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 	err := cmdMain(os.Stdout, os.Args[1:])
 	if err != nil {
 		panic(err)
@@ -17,6 +17,11 @@ func cmdModUsage(o io.Writer) {
 	fmt.Fprintln(o, "The commands are:")
 	fmt.Fprintln(o, "    init    Initialize a new module")
 	fmt.Fprintln(o, "    get     Download modules to the local module cache")
+	fmt.Fprintln(o, "    update  Update the dependencies tracking a branch to its tip")
+	fmt.Fprintln(o, "    pack    Create the module archive of a local module")
+	fmt.Fprintln(o, "    push    Publish a local module to an OCI registry")
+	fmt.Fprintln(o, "    audit   Check the locked modules against the module policy")
+	fmt.Fprintln(o, "    vendor  Archive the locked modules in the root module")
 	fmt.Fprintln(o)
 	fmt.Fprintln(o, "Use \"custodian mod <command> -h\" for more information about a command.")
 }
@@ -41,6 +46,16 @@ func cmdModMain(o io.Writer, args []string) error {
 		return cmdModInitMain(o, nargs[1:])
 	case "get":
 		return cmdModGetMain(o, nargs[1:])
+	case "update":
+		return cmdModUpdateMain(o, nargs[1:])
+	case "pack":
+		return cmdModPackMain(o, nargs[1:])
+	case "push":
+		return cmdModPushMain(o, nargs[1:])
+	case "audit":
+		return cmdModAuditMain(o, nargs[1:])
+	case "vendor":
+		return cmdModVendorMain(o, nargs[1:])
 	default:
 		cmdModUsage(o)
 		fmt.Printf("error: unknown command - %q\n", nargs[0])
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/policy"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/resolvers"
+)
+
+// modAudit prints the modules of the lock file forbidden by the policy and
+// returns how many there are.
+func modAudit(o io.Writer, modulePolicy *policy.Policy, lockData *modules.LockFile) int {
+	violations := 0
+	for _, entry := range lockData.Modules {
+		remote, version, checked := resolvers.PolicyTarget(entry.Module)
+		if !checked {
+			continue
+		}
+		if err := modulePolicy.Check(remote, version); err != nil {
+			fmt.Fprintln(o, err)
+			violations++
+		}
+	}
+	return violations
+}
+
+func cmdModAuditUsage(o io.Writer) {
+	fmt.Fprintln(o, "Custodian mod audit checks every module of module.lock against the policy")
+	fmt.Fprintln(o, "named by CUSTODIAN_POLICY, or custodian.policy.json, and exits with a")
+	fmt.Fprintln(o, "non-zero status if any of them is forbidden. Both files are read from the")
+	fmt.Fprintln(o, "root module: the nearest directory with a custodian.json file.")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Usage:")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "    custodian mod audit")
+}
+
+func cmdModAuditMain(o io.Writer, args []string) error {
+	audit := flag.NewFlagSet("audit", flag.ExitOnError)
+
+	audit.Usage = func() {
+		cmdModAuditUsage(o)
+	}
+
+	audit.Parse(args)
+
+	// the policy and lock file of the root module, as for custodian jsonnet
+	rootDir, err := modules.FindModuleDir(".")
+	if err != nil {
+		return err
+	}
+	if rootDir == "" {
+		rootDir = "."
+	}
+	modulePolicy, err := policy.Load(rootDir)
+	if err != nil {
+		return err
+	}
+	if modulePolicy == nil {
+		return fmt.Errorf("no policy to audit against: create %s or set %s", policy.PolicyFileName, policy.ENV_POLICY)
+	}
+
+	lockData, err := modules.LoadLockFile(rootDir)
+	if err != nil {
+		return err
+	}
+	if lockData == nil {
+		return fmt.Errorf("no %s in %s, run custodian mod get first", modules.LockFileName, rootDir)
+	}
+
+	if violations := modAudit(o, modulePolicy, lockData); violations > 0 {
+		fmt.Fprintf(o, "%d of %d modules forbidden by policy\n", violations, len(lockData.Modules))
+		os.Exit(1)
+	}
+	fmt.Fprintf(o, "%d modules allowed by policy\n", len(lockData.Modules))
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
 
 	"github.com/git-justanotherone/jsonnet-custodian/cmd/internal/utils"
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
@@ -13,7 +14,6 @@ import (
 
 const (
 	MODULE_CACHE_DIR = "/tmp/jnetx/modules"
-	LOCK_FILE_NAME   = "module.lock"
 )
 
 func cmdModGetUsage(o io.Writer) {
@@ -72,15 +72,23 @@ func cmdModGetMain(o io.Writer, args []string) error {
 		}
 	}
 
+	var updated []string
 	for _, moduleIdentifier := range nargs {
 		resolvedIdentifier, err := utils.GetModule(moduleIdentifier)
 		if err != nil {
 			return err
 		}
-		mId := resolvers.GitModuleIdentifier(moduleIdentifier)
-		moduleData.Require[mId.Repo()] = resolvedIdentifier
+		moduleData.Require[resolvers.ModuleName(moduleIdentifier)] = resolvedIdentifier
+		updated = append(updated, resolvedIdentifier)
 	}
 
+	return saveModuleFile(moduleData, updated...)
+}
+
+// saveModuleFile writes back the module file and the lock file of its
+// dependency tree. The lock entries of the updated modules are recomputed,
+// e.g. to follow a moved OCI tag.
+func saveModuleFile(moduleData *modules.ModuleFile, updated ...string) error {
 	// Serialize and write back the updated module file
 	data, err := modules.SerializeModuleFile(moduleData)
 	if err != nil {
@@ -91,13 +99,26 @@ func cmdModGetMain(o io.Writer, args []string) error {
 		return err
 	}
 
-	// Get the full dependency tree and write the lock file
-	dt, err := utils.GetDependencyTree()
+	// Get the full dependency tree, checking the modules already locked,
+	// and write the lock file
+	lockFile, err := modules.LoadLockFile(".")
+	if err != nil {
+		return err
+	}
+	if lockFile != nil {
+		lockFile.Modules = slices.DeleteFunc(lockFile.Modules, func(entry modules.LockEntry) bool {
+			return slices.Contains(updated, entry.Module)
+		})
+	}
+	dt, err := utils.GetDependencyTree(resolvers.WithLockFile(lockFile))
+	if err != nil {
+		return err
+	}
+	lockFileData, err := dt.GenerateLockFile()
 	if err != nil {
 		return err
 	}
-	lockFileData := dt.GenerateLockFile()
-	err = os.WriteFile(LOCK_FILE_NAME, lockFileData, 0644)
+	err = os.WriteFile(modules.LockFileName, lockFileData, 0644)
 	if err != nil {
 		return err
 	}
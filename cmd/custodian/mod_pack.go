@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/resolvers"
+
+	"golang.org/x/mod/semver"
+)
+
+// modPack writes the archive of the module in dir at the given version to
+// output and prints its hash.
+func modPack(o io.Writer, dir, version, output string) error {
+	if !semver.IsValid(version) || semver.Canonical(version) != version {
+		return fmt.Errorf("invalid version %q: must be a canonical semantic version such as v1.2.3", version)
+	}
+	moduleName, err := modules.ReadModuleName(dir)
+	if err != nil {
+		return err
+	}
+	moduleIdentifier := moduleName + resolvers.VersionSeparator + version
+	if output == "" {
+		output = path.Base(moduleName) + resolvers.VersionSeparator + version + ".zip"
+	}
+
+	var buf bytes.Buffer
+	if err := modzip.CreateFromDir(&buf, moduleIdentifier, dir); err != nil {
+		return err
+	}
+	hash, err := modzip.HashZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), moduleIdentifier)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(o, "%s %s\n", moduleIdentifier, hash)
+	return nil
+}
+
+func cmdModPackUsage(o io.Writer) {
+	fmt.Fprintln(o, "Custodian mod pack creates the module archive of a local module, as served")
+	fmt.Fprintln(o, "by module proxies, and prints its hash.")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Usage:")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "    custodian mod pack -version <version> [-o file] [dir]")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Flags:")
+	fmt.Fprintln(o, "    -version    Version of the module, e.g. v1.2.3")
+	fmt.Fprintln(o, "    -o          Output file (default \"<name>@<version>.zip\")")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Arguments:")
+	fmt.Fprintln(o, "    dir    Module directory (default \".\")")
+	fmt.Fprintln(o)
+}
+
+func cmdModPackMain(o io.Writer, args []string) error {
+	pack := flag.NewFlagSet("pack", flag.ExitOnError)
+
+	pack.Usage = func() {
+		cmdModPackUsage(o)
+	}
+	version := pack.String("version", "", "version of the module")
+	output := pack.String("o", "", "output file")
+
+	pack.Parse(args)
+	nargs := pack.Args()
+	if *version == "" || len(nargs) > 1 {
+		pack.Usage()
+		os.Exit(1)
+	}
+
+	dir := "."
+	if len(nargs) == 1 {
+		dir = nargs[0]
+	}
+	return modPack(o, dir, *version, *output)
+}
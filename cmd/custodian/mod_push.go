@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/oci"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/resolvers"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// modPush pushes the module in dir to the OCI reference and prints the
+// identifier pinning the pushed digest.
+func modPush(o io.Writer, reference, dir string) error {
+	ref, err := oci.ParseReference(reference)
+	if err != nil {
+		return err
+	}
+	tag, ok := ref.(name.Tag)
+	if !ok {
+		return fmt.Errorf("%s: modules are pushed to a tag, e.g. %sregistry/repo:v1.0.0", reference, oci.Scheme)
+	}
+	moduleName, err := modules.ReadModuleName(dir)
+	if err != nil {
+		return err
+	}
+
+	moduleIdentifier := moduleName + resolvers.VersionSeparator + tag.TagStr()
+	digest, err := oci.Push(context.Background(), tag, moduleIdentifier, dir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(o, "Pushed '%s' to '%s'.\n", moduleIdentifier, reference)
+	fmt.Fprintf(o, "%s%s@%s\n", oci.Scheme, tag.Context().Name(), digest)
+	return nil
+}
+
+func cmdModPushUsage(o io.Writer) {
+	fmt.Fprintln(o, "Custodian mod push publishes a local module as an OCI artifact.")
+	fmt.Fprintln(o, "Registry credentials are read from the docker configuration.")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Usage:")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "    custodian mod push <reference> [dir]")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Arguments:")
+	fmt.Fprintln(o, "    reference    OCI reference to push to, e.g. oci://registry.example.com/jsonnet/lib:v1.0.0")
+	fmt.Fprintln(o, "    dir          Module directory (default \".\")")
+	fmt.Fprintln(o)
+}
+
+func cmdModPushMain(o io.Writer, args []string) error {
+	push := flag.NewFlagSet("push", flag.ExitOnError)
+
+	push.Usage = func() {
+		cmdModPushUsage(o)
+	}
+
+	push.Parse(args)
+	nargs := push.Args()
+	if len(nargs) == 0 || len(nargs) > 2 {
+		push.Usage()
+		os.Exit(1)
+	}
+
+	dir := "."
+	if len(nargs) == 2 {
+		dir = nargs[1]
+	}
+	return modPush(o, nargs[0], dir)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/git-justanotherone/jsonnet-custodian/cmd/internal/utils"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/resolvers"
+)
+
+func cmdModUpdateUsage(o io.Writer) {
+	fmt.Fprintln(o, "Custodian mod update re-resolves the dependencies tracking a branch, such as")
+	fmt.Fprintln(o, "github.com/org/repo/main, to the current tip of their branch, and updates")
+	fmt.Fprintln(o, "the custodian.json and module.lock files.")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Usage:")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "    custodian mod update [name...]")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Arguments:")
+	fmt.Fprintln(o, "    name    Name of a dependency in custodian.json (if omitted, updates all")
+	fmt.Fprintln(o, "            dependencies tracking a branch)")
+}
+
+func cmdModUpdateMain(o io.Writer, args []string) error {
+	update := flag.NewFlagSet("update", flag.ExitOnError)
+
+	update.Usage = func() {
+		cmdModUpdateUsage(o)
+	}
+
+	update.Parse(args)
+	names := update.Args()
+
+	// Open and parse the module file
+	moduleFile, err := os.Open(modules.ModuleFileName)
+	if err != nil {
+		return fmt.Errorf("failed to open module file: %w", err)
+	}
+	moduleData, err := modules.ParseModuleFile(moduleFile)
+	moduleFile.Close()
+	if err != nil {
+		return fmt.Errorf("failed to parse module file: %w", err)
+	}
+
+	for _, name := range names {
+		moduleIdentifier, exists := moduleData.Require[name]
+		if !exists {
+			return fmt.Errorf("no dependency named %q in %s", name, modules.ModuleFileName)
+		}
+		if _, tracking := resolvers.BranchTip(moduleIdentifier); !tracking {
+			return fmt.Errorf("dependency %q does not track a branch: %s", name, moduleIdentifier)
+		}
+	}
+
+	for moduleName, moduleIdentifier := range moduleData.Require {
+		if len(names) > 0 && !slices.Contains(names, moduleName) {
+			continue
+		}
+		tip, tracking := resolvers.BranchTip(moduleIdentifier)
+		if !tracking {
+			continue
+		}
+		resolvedIdentifier, err := utils.GetModule(tip)
+		if err != nil {
+			return err
+		}
+		moduleData.Require[moduleName] = resolvedIdentifier
+	}
+
+	return saveModuleFile(moduleData)
+}
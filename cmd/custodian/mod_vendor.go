@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/git-justanotherone/jsonnet-custodian/cmd/internal/utils"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/resolvers"
+)
+
+// modVendor replaces vendorDir with the archives of the modules of the lock
+// file, taken from the dependency tree.
+func modVendor(o io.Writer, vendorDir string, lockData *modules.LockFile, dt custodian.DependencyTree) error {
+	// fill a new directory, so that a failure leaves the old one intact
+	tmpDir, err := os.MkdirTemp(filepath.Dir(vendorDir), "."+filepath.Base(vendorDir)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, entry := range lockData.Modules {
+		module, exists := dt.GetModule(entry.Module)
+		if !exists {
+			return fmt.Errorf("%s: not in the dependency tree, run custodian mod get first", entry.Module)
+		}
+		archive, err := os.Create(resolvers.VendorArchivePath(tmpDir, entry.Module))
+		if err != nil {
+			return err
+		}
+		err = modzip.Create(archive, entry.Module, module.FileSystem())
+		if closeErr := archive.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to vendor %s: %w", entry.Module, err)
+		}
+	}
+
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(vendorDir); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpDir, vendorDir); err != nil {
+		return err
+	}
+	fmt.Fprintf(o, "%d modules vendored in %s\n", len(lockData.Modules), vendorDir)
+	return nil
+}
+
+func cmdModVendorUsage(o io.Writer) {
+	fmt.Fprintln(o, "Custodian mod vendor writes the module archive of every module of module.lock")
+	fmt.Fprintln(o, "to the custodian.vendor directory. custodian jsonnet then loads these modules")
+	fmt.Fprintln(o, "from their archive instead of fetching them.")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Usage:")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "    custodian mod vendor")
+}
+
+func cmdModVendorMain(o io.Writer, args []string) error {
+	vendor := flag.NewFlagSet("vendor", flag.ExitOnError)
+
+	vendor.Usage = func() {
+		cmdModVendorUsage(o)
+	}
+
+	vendor.Parse(args)
+	if vendor.NArg() != 0 {
+		vendor.Usage()
+		os.Exit(1)
+	}
+
+	lockData, err := modules.LoadLockFile(".")
+	if err != nil {
+		return err
+	}
+	if lockData == nil {
+		return fmt.Errorf("no %s to vendor, run custodian mod get first", modules.LockFileName)
+	}
+	// fetch the modules rather than reading the archives being replaced
+	dt, err := utils.GetDependencyTree(resolvers.WithLockFile(lockData))
+	if err != nil {
+		return err
+	}
+	return modVendor(o, modules.VendorDirName, lockData, dt)
+}
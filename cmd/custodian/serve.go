@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/git-justanotherone/jsonnet-custodian/cmd/internal/utils"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/proxy"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/resolvers"
+)
+
+func cmdServeUsage(o io.Writer) {
+	fmt.Fprintln(o, "Custodian serve runs a module proxy serving modules from the local module")
+	fmt.Fprintln(o, "cache, fetching missing modules with the git resolver (or CUSTODIAN_PROXY).")
+	fmt.Fprintln(o, "Point clients at it with CUSTODIAN_PROXY=http://<addr>,direct.")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Usage:")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "    custodian serve [-addr address] [-cache dir]")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Flags:")
+	fmt.Fprintln(o, "    -addr     Address to listen on (default \":8080\")")
+	fmt.Fprintf(o, "    -cache    Module cache directory (default %q)\n", utils.MODULE_CACHE_DIR)
+}
+
+func cmdServeMain(o io.Writer, args []string) error {
+	serve := flag.NewFlagSet("serve", flag.ExitOnError)
+
+	serve.Usage = func() {
+		cmdServeUsage(o)
+	}
+	addr := serve.String("addr", ":8080", "address to listen on")
+	cacheDir := serve.String("cache", utils.MODULE_CACHE_DIR, "module cache directory")
+
+	serve.Parse(args)
+
+	resolver, err := resolvers.NewResolver(*cacheDir)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Serving modules from %s on %s", *cacheDir, *addr)
+	return http.ListenAndServe(*addr, proxy.NewHandler(resolver))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/git-justanotherone/jsonnet-custodian/cmd/internal/utils"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/resolvers"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/sumdb"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+const (
+	SUMDB_DIR      = "sumdb"
+	SUMDB_KEY_FILE = "key"
+)
+
+func cmdSumdbUsage(o io.Writer) {
+	fmt.Fprintln(o, "Custodian sumdb runs a checksum database: an append-only log, signed by the")
+	fmt.Fprintln(o, "server, of the hash of each module the first time it is looked up. Modules")
+	fmt.Fprintln(o, "are fetched with the git resolver (or CUSTODIAN_PROXY). Point clients at it")
+	fmt.Fprintln(o, "with CUSTODIAN_SUMDB=\"<verifier key> http://<addr>\".")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Usage:")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "    custodian sumdb [-addr address] [-dir dir] [-name name] [-cache dir]")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Flags:")
+	fmt.Fprintln(o, "    -addr     Address to listen on (default \":8081\")")
+	fmt.Fprintf(o, "    -dir      Directory of the log and of its signing key (default %q)\n", SUMDB_DIR)
+	fmt.Fprintln(o, "    -name     Name of the signing key generated on the first run (default the host name)")
+	fmt.Fprintf(o, "    -cache    Module cache directory (default %q)\n", utils.MODULE_CACHE_DIR)
+}
+
+// loadSumdbKey reads the signing key of the log in dir, generating one named
+// name if there is none, and returns it with its verifier key.
+func loadSumdbKey(dir, name string) (note.Signer, string, error) {
+	keyPath := filepath.Join(dir, SUMDB_KEY_FILE)
+	skey, err := os.ReadFile(keyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		generated, vkey, err := note.GenerateKey(rand.Reader, name)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, "", err
+		}
+		if err := os.WriteFile(keyPath, []byte(generated+"\n"), 0600); err != nil {
+			return nil, "", err
+		}
+		if err := os.WriteFile(keyPath+".pub", []byte(vkey+"\n"), 0644); err != nil {
+			return nil, "", err
+		}
+		skey = []byte(generated)
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	signer, err := note.NewSigner(strings.TrimSpace(string(skey)))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid key %s: %w", keyPath, err)
+	}
+	vkey, err := os.ReadFile(keyPath + ".pub")
+	if err != nil {
+		return nil, "", err
+	}
+	return signer, strings.TrimSpace(string(vkey)), nil
+}
+
+func cmdSumdbMain(o io.Writer, args []string) error {
+	sumdbFlags := flag.NewFlagSet("sumdb", flag.ExitOnError)
+
+	sumdbFlags.Usage = func() {
+		cmdSumdbUsage(o)
+	}
+	addr := sumdbFlags.String("addr", ":8081", "address to listen on")
+	dir := sumdbFlags.String("dir", SUMDB_DIR, "directory of the log and of its signing key")
+	name := sumdbFlags.String("name", "", "name of the generated signing key")
+	cacheDir := sumdbFlags.String("cache", utils.MODULE_CACHE_DIR, "module cache directory")
+
+	sumdbFlags.Parse(args)
+
+	if *name == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		*name = hostname
+	}
+	signer, vkey, err := loadSumdbKey(*dir, *name)
+	if err != nil {
+		return err
+	}
+	checksumLog, err := sumdb.OpenLog(*dir, signer)
+	if err != nil {
+		return err
+	}
+	defer checksumLog.Close()
+
+	resolver, err := resolvers.NewResolver(*cacheDir)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Serving checksum database %s on %s", *dir, *addr)
+	fmt.Fprintf(o, "Verifier key: %s\n", vkey)
+	return http.ListenAndServe(*addr, sumdb.NewHandler(checksumLog, resolver))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func cmdWorkUsage(o io.Writer) {
+	fmt.Fprintln(o, "Custodian work provides workspace management for jsonnet.")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "A workspace is a custodian.work file listing local module directories.")
+	fmt.Fprintln(o, "While it is present, every dependency on one of those modules, at any")
+	fmt.Fprintln(o, "version and anywhere in the dependency tree, is resolved to the local")
+	fmt.Fprintln(o, "directory instead. Set CUSTODIAN_WORK=off to disable it.")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Usage:")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "    custodian work <command> [arguments]")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "The commands are:")
+	fmt.Fprintln(o, "    init    Initialize a new workspace")
+	fmt.Fprintln(o, "    use     Add module directories to the workspace")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Use \"custodian work <command> -h\" for more information about a command.")
+}
+
+func cmdWorkMain(o io.Writer, args []string) error {
+	work := flag.NewFlagSet("work", flag.ExitOnError)
+
+	work.Usage = func() {
+		cmdWorkUsage(o)
+	}
+
+	work.Parse(args)
+	nargs := work.Args()
+	if len(nargs) == 0 {
+		cmdWorkUsage(o)
+		os.Exit(1)
+	}
+
+	switch nargs[0] {
+	case "init":
+		return cmdWorkInitMain(o, nargs[1:])
+	case "use":
+		return cmdWorkUseMain(o, nargs[1:])
+	default:
+		cmdWorkUsage(o)
+		fmt.Printf("error: unknown command - %q\n", nargs[0])
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+)
+
+func workInit(o io.Writer, dirs []string) error {
+	if _, err := os.Stat(modules.WorkFileName); err == nil {
+		return fmt.Errorf("work file '%s' already exists", modules.WorkFileName)
+	}
+
+	workFile := &modules.WorkFile{
+		Use: []string{},
+	}
+	if err := workUse(o, workFile, dirs); err != nil {
+		return err
+	}
+	if err := writeWorkFile(workFile); err != nil {
+		return err
+	}
+	fmt.Fprintf(o, "Workspace '%s' initialized successfully.\n", modules.WorkFileName)
+	return nil
+}
+
+func cmdWorkInitUsage(o io.Writer) {
+	fmt.Fprintln(o, "Custodian work init initializes a new workspace in the current directory.")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Usage:")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "    custodian work init [dirs]")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Arguments:")
+	fmt.Fprintln(o, "    dirs    Module directories to add to the workspace")
+	fmt.Fprintln(o)
+}
+
+func cmdWorkInitMain(o io.Writer, args []string) error {
+	init := flag.NewFlagSet("init", flag.ExitOnError)
+
+	init.Usage = func() {
+		cmdWorkInitUsage(o)
+	}
+
+	init.Parse(args)
+	return workInit(o, init.Args())
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
+)
+
+// workUse adds dirs to the work file, checking that each one is a module.
+// Paths are stored relative to the work file in the current directory.
+func workUse(o io.Writer, workFile *modules.WorkFile, dirs []string) error {
+	for _, dir := range dirs {
+		moduleName, err := modules.ReadModuleName(dir)
+		if err != nil {
+			return err
+		}
+		dir = filepath.ToSlash(filepath.Clean(dir))
+		if !utils.IsLocalPath(dir) {
+			dir = "./" + dir
+		}
+		if slices.Contains(workFile.Use, dir) {
+			continue
+		}
+		workFile.Use = append(workFile.Use, dir)
+		fmt.Fprintf(o, "Using module '%s' from '%s'.\n", moduleName, dir)
+	}
+	return nil
+}
+
+func writeWorkFile(workFile *modules.WorkFile) error {
+	data, err := modules.SerializeWorkFile(workFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(modules.WorkFileName, data, 0644)
+}
+
+func cmdWorkUseUsage(o io.Writer) {
+	fmt.Fprintln(o, "Custodian work use adds module directories to the workspace in the")
+	fmt.Fprintln(o, "current directory.")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Usage:")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "    custodian work use <dirs>")
+	fmt.Fprintln(o)
+	fmt.Fprintln(o, "Arguments:")
+	fmt.Fprintln(o, "    dirs    Module directories to add to the workspace")
+	fmt.Fprintln(o)
+}
+
+func cmdWorkUseMain(o io.Writer, args []string) error {
+	use := flag.NewFlagSet("use", flag.ExitOnError)
+
+	use.Usage = func() {
+		cmdWorkUseUsage(o)
+	}
+
+	use.Parse(args)
+	nargs := use.Args()
+	if len(nargs) == 0 {
+		use.Usage()
+		os.Exit(1)
+	}
+
+	file, err := os.Open(modules.WorkFileName)
+	if err != nil {
+		return fmt.Errorf("failed to open work file: %w", err)
+	}
+	workFile, err := modules.ParseWorkFile(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to parse work file: %w", err)
+	}
+
+	if err := workUse(o, workFile, nargs); err != nil {
+		return err
+	}
+	return writeWorkFile(workFile)
+}
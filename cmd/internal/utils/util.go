@@ -3,7 +3,10 @@ package utils
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
@@ -15,12 +18,15 @@ import (
 
 const (
 	MODULE_CACHE_DIR = "/tmp/jnetx/modules"
-	LOCK_FILE_NAME   = "module.lock"
+	ENV_WORK         = resolvers.ENV_PREFIX + "WORK"
+	// ENV_IMPORT_CACHE names a directory keeping the outputs of the
+	// non-secret transformers across runs.
+	ENV_IMPORT_CACHE = resolvers.ENV_PREFIX + "IMPORT_CACHE"
 )
 
-func GetDependencyTree() (custodian.DependencyTree, error) {
+func GetDependencyTree(opts ...resolvers.Option) (custodian.DependencyTree, error) {
 	// Create a Resolver
-	moduleResolver, err := resolvers.NewResolver(MODULE_CACHE_DIR)
+	moduleResolver, err := resolvers.NewResolver(MODULE_CACHE_DIR, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -56,17 +62,174 @@ func GetModule(moduleIdentifier string) (string, error) {
 
 }
 
-func ConfigureVMExtensions(vm *jsonnet.VM) error {
-	// Set up the GitImporter with the dependency tree.
-	dt, err := GetDependencyTree()
+// FindWorkspace loads the workspace of dir. The work file is looked up in
+// dir and its parents unless the CUSTODIAN_WORK environment variable names
+// one explicitly, or is "off". It returns nil if there is no workspace.
+func FindWorkspace(dir string) (*modules.Workspace, error) {
+	workFilePath := os.Getenv(ENV_WORK)
+	switch workFilePath {
+	case "off":
+		return nil, nil
+	case "":
+		var err error
+		workFilePath, err = modules.FindWorkFile(dir)
+		if err != nil || workFilePath == "" {
+			return nil, err
+		}
+	}
+	return modules.LoadWorkspace(workFilePath)
+}
+
+// VMConfig holds the options of the jsonnet command applied by
+// ConfigureVMExtensions.
+type VMConfig struct {
+	// JPaths are the library paths of -J and JSONNET_PATH, right-most
+	// first, searched for the imports no module provides.
+	JPaths []string
+	// InputFile is the file evaluated, "-" for the standard input, or the
+	// code evaluated if InputIsCode.
+	InputFile   string
+	InputIsCode bool
+	// TracePaths selects how import locations read in errors, traces and
+	// dependencies, modules.TracePathsModule when empty.
+	TracePaths string
+	// TraceImports logs the imports to the standard error.
+	TraceImports bool
+	// Cache is shared by the importers of the VMs configured with it, the
+	// process-wide defaultImportCache when nil.
+	Cache *modules.ImportCache
+}
+
+// defaultImportCache is the import cache of the VMs configured without one,
+// kept in CUSTODIAN_IMPORT_CACHE across runs.
+var defaultImportCache = sync.OnceValue(func() *modules.ImportCache {
+	return modules.NewImportCache(os.Getenv(ENV_IMPORT_CACHE))
+})
+
+// entryFile returns the name of the input file, or "" for snippets.
+func (config VMConfig) entryFile() string {
+	if config.InputIsCode || config.InputFile == "-" {
+		return ""
+	}
+	return config.InputFile
+}
+
+// findRootModule returns the directory of the root module of the input: the
+// nearest directory with a module file above the input file, or above the
+// current directory for code and the standard input. Without module file,
+// the root module is the current directory, or the directory of an input
+// file outside of it. It also returns the path of the input in the root
+// module.
+func findRootModule(config VMConfig) (rootDir, entryPath string, err error) {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+	// snippets are evaluated in the current directory
+	entryFile := filepath.Join(workDir, "<snippet>")
+	if config.entryFile() != "" {
+		if entryFile, err = filepath.Abs(config.entryFile()); err != nil {
+			return "", "", err
+		}
+	}
+
+	rootDir, err = modules.FindModuleDir(filepath.Dir(entryFile))
+	if err != nil {
+		return "", "", err
+	}
+	if rootDir == "" {
+		rootDir = workDir
+		if relPath, err := filepath.Rel(workDir, entryFile); err != nil || !filepath.IsLocal(relPath) {
+			rootDir = filepath.Dir(entryFile)
+		}
+	}
+	entryPath, err = filepath.Rel(rootDir, entryFile)
 	if err != nil {
-		return err
+		return "", "", err
+	}
+	return rootDir, filepath.ToSlash(entryPath), nil
+}
+
+// locationFormatter formats errors with the import locations as they read
+// for tracePaths.
+type locationFormatter struct {
+	jsonnet.ErrorFormatter
+	importer   *modules.GitImporter
+	tracePaths string
+}
+
+func (formatter locationFormatter) Format(err error) string {
+	return formatter.importer.DisplayLocations(formatter.ErrorFormatter.Format(err), formatter.tracePaths)
+}
+
+// locationWriter writes std.trace output with the import locations as they
+// read for tracePaths.
+type locationWriter struct {
+	io.Writer
+	importer   *modules.GitImporter
+	tracePaths string
+}
+
+func (writer locationWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(writer.Writer, writer.importer.DisplayLocations(string(p), writer.tracePaths)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ConfigureVMExtensions sets up vm to import modules, and returns its
+// importer, which records the files the evaluation imports.
+func ConfigureVMExtensions(vm *jsonnet.VM, config VMConfig) (*modules.GitImporter, error) {
+	switch config.TracePaths {
+	case "":
+		config.TracePaths = modules.TracePathsModule
+	case modules.TracePathsModule, modules.TracePathsCache:
+	default:
+		return nil, fmt.Errorf("invalid --trace-paths value: %q", config.TracePaths)
+	}
+	rootDir, entryPath, err := findRootModule(config)
+	if err != nil {
+		return nil, err
+	}
+	workspace, err := FindWorkspace(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	lockFile, err := modules.LoadLockFile(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	// Set up the GitImporter with the dependency tree, preferring the
+	// vendored modules.
+	dt, err := GetDependencyTree(
+		resolvers.WithWorkspace(workspace),
+		resolvers.WithBaseDir(rootDir),
+		resolvers.WithLockFile(lockFile),
+		resolvers.WithVendorDir(filepath.Join(rootDir, modules.VendorDirName)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if config.Cache == nil {
+		config.Cache = defaultImportCache()
 	}
 	importer := &modules.GitImporter{
 		DependencyTree: dt,
+		JPaths:         config.JPaths,
+		EntryFile:      config.entryFile(),
+		EntryPath:      entryPath,
+		Cache:          config.Cache,
+		TracePaths:     config.TracePaths,
+	}
+	if config.TraceImports {
+		importer.TraceOut = os.Stderr
 	}
 	// Add SOPS decryption transformer.
-	importer.AddTransformer(transformers.SopsDecryptorTransformer)
+	importer.AddSecretTransformer("sops", "", transformers.SopsDecryptorTransformer)
 	vm.Importer(importer)
-	return nil
+	// errors and traces show readable locations instead of the foundAt of
+	// the importer
+	vm.ErrorFormatter = locationFormatter{vm.ErrorFormatter, importer, config.TracePaths}
+	vm.SetTraceOut(locationWriter{os.Stderr, importer, config.TracePaths})
+	return importer, nil
 }
@@ -7,7 +7,7 @@ import (
 
 type DependencyTree interface {
 	GetModule(moduleIdentifier string) (Module, bool)
-	GenerateLockFile() []byte
+	GenerateLockFile() ([]byte, error)
 	RootIdentifier() string
 }
 type Module interface {
@@ -16,6 +16,32 @@ type Module interface {
 	FileSystem() fs.FS
 	Identifier() string
 }
+
+// DigestModule is implemented by modules whose identifier does not pin their
+// content, such as OCI tags, to report the digest they were resolved to.
+type DigestModule interface {
+	Module
+	Digest() string
+}
+
+// BranchModule is implemented by modules resolved from the tip of a branch,
+// to report the branch they track.
+type BranchModule interface {
+	Module
+	Branch() string
+}
+
+// SignedModule is implemented by modules whose signature was verified, to
+// report the key that signed them.
+type SignedModule interface {
+	Module
+	Signer() string
+}
+
 type Resolver interface {
 	Resolve(ctx context.Context, moduleIdentifier string) (Module, error)
 }
+
+type VersionLister interface {
+	ListVersions(ctx context.Context, remoteIdentifier string) ([]string, error)
+}
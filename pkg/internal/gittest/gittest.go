@@ -0,0 +1,284 @@
+// Package gittest builds throwaway git repositories for tests and serves
+// them over file:// URLs or an in-process smart-HTTP server, so the git
+// resolvers can be tested without network access.
+package gittest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+// Signature is the author and committer of every commit and annotated tag.
+var Signature = object.Signature{Name: "gittest", Email: "gittest@example.com"}
+
+// Repo is a git repository with a worktree in a temporary directory. Its
+// default branch is main.
+type Repo struct {
+	Dir        string
+	Repository *git.Repository
+
+	t        testing.TB
+	worktree *git.Worktree
+}
+
+// NewRepo initializes an empty repository, removed at the end of the test.
+func NewRepo(t testing.TB) *Repo {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.Main},
+	})
+	if err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+	return &Repo{Dir: dir, Repository: repo, t: t, worktree: worktree}
+}
+
+// URL returns the file:// URL of the repository. Fetching from it needs
+// git-upload-pack, see RequireGit.
+func (r *Repo) URL() string {
+	return "file://" + filepath.ToSlash(r.Dir)
+}
+
+// RequireGit skips the test if git-upload-pack, which serves file:// URLs
+// to go-git, is not installed, either in PATH or in the exec path of git.
+func RequireGit(t testing.TB) {
+	t.Helper()
+	if _, err := exec.LookPath("git-upload-pack"); err == nil {
+		return
+	}
+	if _, err := exec.LookPath("git"); err == nil {
+		return
+	}
+	t.Skip("git-upload-pack not installed")
+}
+
+// Commit writes files, relative paths to contents, and commits them on the
+// current branch at the given time, used as author and committer time.
+func (r *Repo) Commit(when time.Time, files map[string]string) plumbing.Hash {
+	r.t.Helper()
+	return r.SignedCommit(when, files, nil)
+}
+
+// SignedCommit is Commit with the commit signed by signer, if not nil.
+func (r *Repo) SignedCommit(when time.Time, files map[string]string, signer git.Signer) plumbing.Hash {
+	r.t.Helper()
+	for name, content := range files {
+		filePath := filepath.Join(r.Dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			r.t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			r.t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if _, err := r.worktree.Add(name); err != nil {
+			r.t.Fatalf("Failed to add %s: %v", name, err)
+		}
+	}
+	hash, err := r.worktree.Commit("commit at "+when.Format(time.RFC3339), &git.CommitOptions{
+		Author:            r.signature(when),
+		Committer:         r.signature(when),
+		AllowEmptyCommits: true,
+		Signer:            signer,
+	})
+	if err != nil {
+		r.t.Fatalf("Failed to commit: %v", err)
+	}
+	return hash
+}
+
+// Tag creates a lightweight tag.
+func (r *Repo) Tag(name string, hash plumbing.Hash) {
+	r.t.Helper()
+	if _, err := r.Repository.CreateTag(name, hash, nil); err != nil {
+		r.t.Fatalf("Failed to create tag %s: %v", name, err)
+	}
+}
+
+// AnnotatedTag creates an annotated tag object.
+func (r *Repo) AnnotatedTag(name string, hash plumbing.Hash, when time.Time) {
+	r.t.Helper()
+	_, err := r.Repository.CreateTag(name, hash, &git.CreateTagOptions{
+		Tagger:  r.signature(when),
+		Message: "release " + name,
+	})
+	if err != nil {
+		r.t.Fatalf("Failed to create tag %s: %v", name, err)
+	}
+}
+
+// SignedTag creates an annotated tag object signed by signer.
+func (r *Repo) SignedTag(name string, hash plumbing.Hash, when time.Time, signer git.Signer) {
+	r.t.Helper()
+	tag := &object.Tag{
+		Name:       name,
+		Tagger:     *r.signature(when),
+		Message:    "release " + name + "\n",
+		TargetType: plumbing.CommitObject,
+		Target:     hash,
+	}
+	payload := &plumbing.MemoryObject{}
+	if err := tag.EncodeWithoutSignature(payload); err != nil {
+		r.t.Fatalf("Failed to encode tag %s: %v", name, err)
+	}
+	reader, err := payload.Reader()
+	if err != nil {
+		r.t.Fatalf("Failed to encode tag %s: %v", name, err)
+	}
+	signature, err := signer.Sign(reader)
+	if err != nil {
+		r.t.Fatalf("Failed to sign tag %s: %v", name, err)
+	}
+	tag.PGPSignature = string(signature)
+
+	obj := r.Repository.Storer.NewEncodedObject()
+	if err := tag.Encode(obj); err != nil {
+		r.t.Fatalf("Failed to encode tag %s: %v", name, err)
+	}
+	tagHash, err := r.Repository.Storer.SetEncodedObject(obj)
+	if err != nil {
+		r.t.Fatalf("Failed to store tag %s: %v", name, err)
+	}
+	ref := plumbing.NewHashReference(plumbing.NewTagReferenceName(name), tagHash)
+	if err := r.Repository.Storer.SetReference(ref); err != nil {
+		r.t.Fatalf("Failed to create tag %s: %v", name, err)
+	}
+}
+
+// Branch creates a branch at the current commit and checks it out.
+func (r *Repo) Branch(name string) {
+	r.t.Helper()
+	err := r.worktree.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(name),
+		Create: true,
+	})
+	if err != nil {
+		r.t.Fatalf("Failed to create branch %s: %v", name, err)
+	}
+}
+
+// Checkout checks out an existing branch.
+func (r *Repo) Checkout(name string) {
+	r.t.Helper()
+	if err := r.worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(name)}); err != nil {
+		r.t.Fatalf("Failed to check out %s: %v", name, err)
+	}
+}
+
+func (r *Repo) signature(when time.Time) *object.Signature {
+	signature := Signature
+	signature.When = when
+	return &signature
+}
+
+// Serve serves repos, by URL path such as org/lib, with the smart-HTTP
+// protocol until the end of the test. Only fetching is supported; the pack
+// sent always holds every object reachable from the wanted commits.
+func Serve(t testing.TB, repos map[string]*Repo) *httptest.Server {
+	t.Helper()
+	storers := make(map[string]storer.Storer, len(repos))
+	for repoPath, repo := range repos {
+		storers["/"+strings.Trim(repoPath, "/")] = repo.Repository.Storer
+	}
+	gitServer := server.NewServer(loader(storers))
+
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repoPath, found := strings.CutSuffix(r.URL.Path, "/info/refs")
+		if found && r.Method == http.MethodGet && r.URL.Query().Get("service") == transport.UploadPackServiceName {
+			serveAdvertisedRefs(w, r, gitServer, repoPath)
+			return
+		}
+		repoPath, found = strings.CutSuffix(r.URL.Path, "/"+transport.UploadPackServiceName)
+		if found && r.Method == http.MethodPost {
+			serveUploadPack(w, r, gitServer, repoPath)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(httpServer.Close)
+	return httpServer
+}
+
+// loader maps endpoint paths to repository storers.
+type loader map[string]storer.Storer
+
+func (l loader) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	s, exists := l[strings.TrimSuffix(ep.Path, ".git")]
+	if !exists {
+		return nil, transport.ErrRepositoryNotFound
+	}
+	return s, nil
+}
+
+func uploadPackSession(w http.ResponseWriter, gitServer transport.Transport, repoPath string) (transport.UploadPackSession, bool) {
+	session, err := gitServer.NewUploadPackSession(&transport.Endpoint{Protocol: "http", Path: repoPath}, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	return session, true
+}
+
+func serveAdvertisedRefs(w http.ResponseWriter, r *http.Request, gitServer transport.Transport, repoPath string) {
+	session, ok := uploadPackSession(w, gitServer, repoPath)
+	if !ok {
+		return
+	}
+	defer session.Close()
+	refs, err := session.AdvertisedReferencesContext(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	refs.Prefix = [][]byte{[]byte("# service=" + transport.UploadPackServiceName), pktline.Flush}
+	w.Header().Set("Content-Type", "application/x-"+transport.UploadPackServiceName+"-advertisement")
+	refs.Encode(w)
+}
+
+func serveUploadPack(w http.ResponseWriter, r *http.Request, gitServer transport.Transport, repoPath string) {
+	session, ok := uploadPackSession(w, gitServer, repoPath)
+	if !ok {
+		return
+	}
+	defer session.Close()
+	// the session needs the advertised references before a request
+	if _, err := session.AdvertisedReferencesContext(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// haves are not decoded: clients without objects send none, and the
+	// others receive objects they already have
+	request := packp.NewUploadPackRequest()
+	if err := request.UploadRequest.Decode(r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := session.UploadPack(r.Context(), request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer response.Close()
+	w.Header().Set("Content-Type", "application/x-"+transport.UploadPackServiceName+"-result")
+	response.Encode(w)
+}
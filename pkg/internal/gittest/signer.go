@@ -0,0 +1,55 @@
+package gittest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/pem"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+)
+
+// OpenPGPSigner signs git objects with an OpenPGP key, as git does with
+// gpg.format=openpgp.
+type OpenPGPSigner struct {
+	Entity *openpgp.Entity
+}
+
+func (s OpenPGPSigner) Sign(message io.Reader) ([]byte, error) {
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&signature, s.Entity, message, nil); err != nil {
+		return nil, err
+	}
+	return signature.Bytes(), nil
+}
+
+// SSHSigner signs git objects with an SSH key, as git does with
+// gpg.format=ssh.
+type SSHSigner struct {
+	Key ssh.Signer
+}
+
+func (s SSHSigner) Sign(message io.Reader) ([]byte, error) {
+	const magic, namespace, hashAlgorithm = "SSHSIG", "git", "sha512"
+	h := sha512.New()
+	if _, err := io.Copy(h, message); err != nil {
+		return nil, err
+	}
+	signedData := append([]byte(magic), ssh.Marshal(struct {
+		Namespace, Reserved, HashAlgorithm string
+		Hash                               []byte
+	}{namespace, "", hashAlgorithm, h.Sum(nil)})...)
+	signature, err := s.Key.Sign(rand.Reader, signedData)
+	if err != nil {
+		return nil, err
+	}
+	blob := append([]byte(magic), ssh.Marshal(struct {
+		Version                            uint32
+		PublicKey                          []byte
+		Namespace, Reserved, HashAlgorithm string
+		Signature                          []byte
+	}{1, s.Key.PublicKey().Marshal(), namespace, "", hashAlgorithm, ssh.Marshal(signature)})...)
+	return pem.EncodeToMemory(&pem.Block{Type: "SSH SIGNATURE", Bytes: blob}), nil
+}
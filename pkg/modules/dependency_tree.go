@@ -2,9 +2,12 @@ package modules
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
 )
 
@@ -26,16 +29,34 @@ func (dt *dependencyTree) RootIdentifier() string {
 	return dt.rootIdentifier
 }
 
-func (dt *dependencyTree) GenerateLockFile() []byte {
-	lockData := make([]string, 0, len(dt.modules))
+func (dt *dependencyTree) GenerateLockFile() ([]byte, error) {
+	lockData := &LockFile{Modules: make([]LockEntry, 0, len(dt.modules))}
 	for _, module := range dt.modules {
-		// Only include non-local modules in the lock file
-		if !utils.IsLocalPath(module.Identifier()) {
-			lockData = append(lockData, module.Identifier())
+		// Only include non-local modules in the lock file; versioned local
+		// paths are git repositories and are locked like remote ones
+		if utils.IsLocalPath(module.Identifier()) && !utils.IsVersionedLocalPath(module.Identifier()) {
+			continue
 		}
+		hash, err := modzip.HashFS(module.Identifier(), module.FileSystem())
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash module %s: %w", module.Identifier(), err)
+		}
+		entry := LockEntry{Module: module.Identifier(), Hash: hash}
+		if digestModule, ok := module.(custodian.DigestModule); ok {
+			entry.Digest = digestModule.Digest()
+		}
+		if branchModule, ok := module.(custodian.BranchModule); ok {
+			entry.Branch = branchModule.Branch()
+		}
+		if signedModule, ok := module.(custodian.SignedModule); ok {
+			entry.Signer = signedModule.Signer()
+		}
+		lockData.Modules = append(lockData.Modules, entry)
 	}
-	lockFileBytes, _ := json.MarshalIndent(lockData, "", "  ")
-	return lockFileBytes
+	slices.SortFunc(lockData.Modules, func(a, b LockEntry) int {
+		return strings.Compare(a.Module, b.Module)
+	})
+	return SerializeLockFile(lockData)
 }
 
 func NewDependencyTree(root custodian.Module, resolver custodian.Resolver) (custodian.DependencyTree, error) {
@@ -0,0 +1,118 @@
+package modules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ImportCache caches the files imported by GitImporters, after their
+// transformers, by location on disk, content and transformer chain. It may be
+// shared by the importers of several VMs. A nil ImportCache caches nothing.
+type ImportCache struct {
+	// Dir, when not empty, keeps the outputs of transformers across runs,
+	// except for the outputs of secret transformers.
+	Dir string
+
+	mu      sync.Mutex
+	entries map[importCacheKey]importCacheEntry
+}
+
+type importCacheKey struct {
+	// location is the file on disk, or the location of the module file for
+	// modules not on disk.
+	location     string
+	transformers string
+	// sum is the hash of the file data fed to the transformers, files on
+	// disk changing between imports.
+	sum string
+}
+
+type importCacheEntry struct {
+	Data []byte `json:"data"`
+	// Transformers are the transformers which changed the file.
+	Transformers []string `json:"transformers,omitempty"`
+}
+
+// NewImportCache returns an import cache keeping the outputs of transformers
+// in dir, or only in memory if dir is empty.
+func NewImportCache(dir string) *ImportCache {
+	return &ImportCache{Dir: dir}
+}
+
+// get returns the entry cached in memory for key.
+func (cache *ImportCache) get(key importCacheKey) (importCacheEntry, bool) {
+	if cache == nil {
+		return importCacheEntry{}, false
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	entry, exists := cache.entries[key]
+	return entry, exists
+}
+
+// put caches entry in memory for key.
+func (cache *ImportCache) put(key importCacheKey, entry importCacheEntry) {
+	if cache == nil {
+		return
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.entries == nil {
+		cache.entries = make(map[importCacheKey]importCacheEntry)
+	}
+	cache.entries[key] = entry
+}
+
+// load returns the entry stored in Dir for key.
+func (cache *ImportCache) load(key importCacheKey) (importCacheEntry, bool) {
+	if cache == nil || cache.Dir == "" {
+		return importCacheEntry{}, false
+	}
+	content, err := os.ReadFile(cache.entryPath(key))
+	if err != nil {
+		return importCacheEntry{}, false
+	}
+	var entry importCacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return importCacheEntry{}, false
+	}
+	return entry, true
+}
+
+// store writes entry to Dir for key. Failures are ignored, the cache being
+// an optimization.
+func (cache *ImportCache) store(key importCacheKey, entry importCacheEntry) {
+	if cache == nil || cache.Dir == "" {
+		return
+	}
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	entryPath := cache.entryPath(key)
+	if err := os.MkdirAll(filepath.Dir(entryPath), 0700); err != nil {
+		return
+	}
+	// write atomically, for concurrent runs
+	tmpFile, err := os.CreateTemp(filepath.Dir(entryPath), ".entry-")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(content)
+	if closeErr := tmpFile.Close(); err != nil || closeErr != nil {
+		return
+	}
+	os.Rename(tmpFile.Name(), entryPath)
+}
+
+// entryPath returns the file of Dir keeping the entry of key.
+func (cache *ImportCache) entryPath(key importCacheKey) string {
+	hash := sha256.Sum256([]byte(key.location + "\x00" + key.transformers + "\x00" + key.sum))
+	sum := hex.EncodeToString(hash[:])
+	return filepath.Join(cache.Dir, sum[:2], sum)
+}
@@ -0,0 +1,255 @@
+package modules
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitImporter_Import_cache(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app/main.jsonnet":   "{}",
+		"app/text.upper":     "text",
+		"app/secret.reverse": "terces",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resolver := dirResolver{".": filepath.Join(dir, "app")}
+	root, err := resolver.Resolve(context.Background(), ".")
+	if err != nil {
+		t.Fatalf("Failed to load root module: %v", err)
+	}
+	dependencyTree, err := NewDependencyTree(root, resolver)
+	if err != nil {
+		t.Fatalf("Failed to build dependency tree: %v", err)
+	}
+
+	calls := 0
+	countingUpper := func(foundAt string, data []byte) ([]byte, error) {
+		calls++
+		return upperTransformer(foundAt, data)
+	}
+	reverse := func(foundAt string, data []byte) ([]byte, error) {
+		calls++
+		if filepath.Ext(foundAt) != ".reverse" {
+			return data, nil
+		}
+		reversed := bytes.Clone(data)
+		for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+			reversed[i], reversed[j] = reversed[j], reversed[i]
+		}
+		return reversed, nil
+	}
+	// transformers built from the same function differ by their config
+	suffix := func(suffix string) Transformer {
+		return func(foundAt string, data []byte) ([]byte, error) {
+			return append(bytes.Clone(data), suffix...), nil
+		}
+	}
+	newSuffixImporter := func(cache *ImportCache, config string) *GitImporter {
+		importer := &GitImporter{DependencyTree: dependencyTree, Cache: cache}
+		importer.AddTransformer("suffix", config, suffix(config))
+		return importer
+	}
+	newImporter := func(cache *ImportCache) *GitImporter {
+		importer := &GitImporter{DependencyTree: dependencyTree, Cache: cache}
+		importer.AddTransformer("upper", "", countingUpper)
+		importer.AddSecretTransformer("reverse", "", reverse)
+		return importer
+	}
+	cacheDir := t.TempDir()
+	cache := NewImportCache(cacheDir)
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		importer     *GitImporter
+		importedPath string
+		want         string
+		wantCalls    int
+	}{
+		{
+			name:         "first import",
+			importer:     newImporter(cache),
+			importedPath: "text.upper",
+			want:         "TEXT",
+			wantCalls:    2,
+		},
+		{
+			name:         "importer sharing the cache",
+			importer:     newImporter(cache),
+			importedPath: "text.upper",
+			want:         "TEXT",
+			wantCalls:    0,
+		},
+		{
+			name:         "other transformers",
+			importer:     &GitImporter{DependencyTree: dependencyTree, Cache: cache},
+			importedPath: "text.upper",
+			want:         "text",
+			wantCalls:    0,
+		},
+		{
+			name:         "transformer output on disk",
+			importer:     newImporter(NewImportCache(cacheDir)),
+			importedPath: "text.upper",
+			want:         "TEXT",
+			wantCalls:    0,
+		},
+		{
+			name:         "transformer with a config",
+			importer:     newSuffixImporter(cache, "!"),
+			importedPath: "text.upper",
+			want:         "text!",
+			wantCalls:    0,
+		},
+		{
+			name:         "same transformer with another config",
+			importer:     newSuffixImporter(cache, "?"),
+			importedPath: "text.upper",
+			want:         "text?",
+			wantCalls:    0,
+		},
+		{
+			name:         "first import of a secret",
+			importer:     newImporter(cache),
+			importedPath: "secret.reverse",
+			want:         "secret",
+			wantCalls:    2,
+		},
+		{
+			name:         "secret in memory",
+			importer:     newImporter(cache),
+			importedPath: "secret.reverse",
+			want:         "secret",
+			wantCalls:    0,
+		},
+		{
+			name:         "secret not on disk",
+			importer:     newImporter(NewImportCache(cacheDir)),
+			importedPath: "secret.reverse",
+			want:         "secret",
+			wantCalls:    2,
+		},
+		{
+			name:         "without cache",
+			importer:     newImporter(nil),
+			importedPath: "text.upper",
+			want:         "TEXT",
+			wantCalls:    2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			got, _, gotErr := tt.importer.Import("", tt.importedPath)
+			if gotErr != nil {
+				t.Fatalf("Import() failed: %v", gotErr)
+			}
+			if string(got.Data()) != tt.want || calls != tt.wantCalls {
+				t.Errorf("Import() = %s with %d transformer calls, want %s with %d", got.Data(), calls, tt.want, tt.wantCalls)
+			}
+		})
+	}
+
+	// changed files miss the cache on disk
+	if err := os.WriteFile(filepath.Join(dir, "app", "text.upper"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := newImporter(NewImportCache(cacheDir)).Import("", "text.upper")
+	if err != nil {
+		t.Fatalf("Import() failed: %v", err)
+	}
+	if string(got.Data()) != "CHANGED" {
+		t.Errorf("Import() = %s, want CHANGED", got.Data())
+	}
+}
+
+func TestGitImporter_Import_cacheRoots(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"first/text.upper":  "first",
+		"second/text.upper": "second",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// importers of VMs with different roots share the cache
+	cache := NewImportCache("")
+	newImporter := func(rootDir string) *GitImporter {
+		resolver := dirResolver{".": rootDir}
+		root, err := resolver.Resolve(context.Background(), ".")
+		if err != nil {
+			t.Fatalf("Failed to load root module: %v", err)
+		}
+		dependencyTree, err := NewDependencyTree(root, resolver)
+		if err != nil {
+			t.Fatalf("Failed to build dependency tree: %v", err)
+		}
+		importer := &GitImporter{DependencyTree: dependencyTree, Cache: cache}
+		importer.AddTransformer("upper", "", upperTransformer)
+		return importer
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		rootDir string
+		content string
+		want    string
+	}{
+		{
+			name:    "first root",
+			rootDir: filepath.Join(dir, "first"),
+			want:    "FIRST",
+		},
+		{
+			name:    "second root",
+			rootDir: filepath.Join(dir, "second"),
+			want:    "SECOND",
+		},
+		{
+			name:    "first root again",
+			rootDir: filepath.Join(dir, "first"),
+			want:    "FIRST",
+		},
+		{
+			name:    "edited file",
+			rootDir: filepath.Join(dir, "first"),
+			content: "edited",
+			want:    "EDITED",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.content != "" {
+				if err := os.WriteFile(filepath.Join(tt.rootDir, "text.upper"), []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, _, gotErr := newImporter(tt.rootDir).Import("", "text.upper")
+			if gotErr != nil {
+				t.Fatalf("Import() failed: %v", gotErr)
+			}
+			if string(got.Data()) != tt.want {
+				t.Errorf("Import() = %s, want %s", got.Data(), tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,17 @@
 package modules
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"path"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
@@ -13,17 +21,95 @@ import (
 
 type Transformer func(foundAt string, data []byte) ([]byte, error)
 
+// ImportTransformer is a transformer added to an importer. Name and Config
+// identify it in the import cache: transformers built from the same function
+// with different settings, such as closures over different keys, must differ
+// in Config.
+type ImportTransformer struct {
+	Name      string
+	Config    string
+	Transform Transformer
+	// Secret transformers output secrets, such as decrypted files, which
+	// are never written to the cache directory.
+	Secret bool
+}
+
 type GitImporter struct {
-	Transformers   []Transformer
+	Transformers   []ImportTransformer
 	DependencyTree custodian.DependencyTree
+	// JPaths are library directories searched, right-most first, for the
+	// non-relative imports no module provides, like the -J option of
+	// jsonnet. Each directory is a module named after its path.
+	JPaths []string
+	// EntryFile is the name of the file the VM evaluates, as given on the
+	// command line, and EntryPath its path in the root module. Snippets are
+	// evaluated at EntryPath, without EntryFile.
+	EntryFile string
+	EntryPath string
+	// TraceOut, when not nil, receives a line per import with the module
+	// and the path it resolved to, and the transformers applied.
+	TraceOut io.Writer
+	// Cache, when not nil, keeps the files imported after the transformers,
+	// and may be shared with the importers of other VMs.
+	Cache *ImportCache
+	// TracePaths, when not empty, makes Import return the locations of files
+	// as they read for TracePaths, e.g. in std.thisFile, rather than as
+	// module identifier and path.
+	TracePaths string
+
+	jpathMu      sync.Mutex
+	jpathModules map[string]custodian.Module
+
+	locationsMu sync.Mutex
+	locations   map[string][]string
+	// displayed maps the locations returned for TracePaths to the ones
+	// they display.
+	displayed map[string]string
 }
 
 func (importer *GitImporter) Import(importedFrom string, importedPath string) (contents jsonnet.Contents, foundAt string, err error) {
+	importedFrom = importer.displayedLocation(importedFrom)
+	contents, foundAt, err = importer.importFile(importedFrom, importedPath)
+	if importer.TraceOut != nil {
+		importer.traceImport(importedFrom, importedPath, foundAt, err)
+	}
+	if err != nil {
+		return contents, foundAt, err
+	}
+	return contents, importer.displayLocation(foundAt), nil
+}
+
+// traceImport writes the outcome of an import to TraceOut.
+func (importer *GitImporter) traceImport(importedFrom, importedPath, foundAt string, err error) {
+	line := fmt.Sprintf("IMPORT: %q", importedPath)
+	if importedFrom != "" {
+		line += " from " + utils.DisplayFoundAtPath(importedFrom)
+	}
+	if err != nil {
+		fmt.Fprintf(importer.TraceOut, "%s: %v\n", line, err)
+		return
+	}
+	moduleIdentifier, filePath := utils.ParseImportedFrom(foundAt)
+	line += fmt.Sprintf(": module %s, path %s", moduleIdentifier, filePath)
+	if applied := importer.appliedTransformers(foundAt); len(applied) > 0 {
+		line += ", transformers " + strings.Join(applied, " ")
+	}
+	fmt.Fprintln(importer.TraceOut, line)
+}
+
+func (importer *GitImporter) importFile(importedFrom string, importedPath string) (contents jsonnet.Contents, foundAt string, err error) {
 	var detectedModule custodian.Module
 	var detectedPath string
 
 	// Parse the importedFrom to get the module identifier
 	sourceModuleIdentifier, sourceFilePath := utils.ParseImportedFrom(importedFrom)
+	if importedFrom == "" && importer.EntryPath != "" {
+		// the entry file and the imports of snippets
+		sourceFilePath = importer.EntryPath
+		if importer.EntryFile != "" && importedPath == importer.EntryFile {
+			importedPath = "./" + path.Base(importer.EntryPath)
+		}
+	}
 	if sourceModuleIdentifier == "" {
 		sourceModuleIdentifier = importer.DependencyTree.RootIdentifier()
 	}
@@ -34,6 +120,10 @@ func (importer *GitImporter) Import(importedFrom string, importedPath string) (c
 	// Load the source module from the dependency tree
 	sourceModule, exists := importer.DependencyTree.GetModule(sourceModuleIdentifier)
 	if !exists {
+		// files of library paths import like jsonnet's FileImporter
+		if jpathModule, isJPath := importer.jpathModule(sourceModuleIdentifier); isJPath {
+			return importer.importFromJPath(jpathModule, sourceFilePath, importedPath)
+		}
 		return jsonnet.MakeContents(""), "", fmt.Errorf("module not found: %s", sourceModuleIdentifier)
 	}
 
@@ -61,29 +151,156 @@ func (importer *GitImporter) Import(importedFrom string, importedPath string) (c
 		return jsonnet.MakeContents(""), "", fmt.Errorf("dependency module not found: %s", dependencyModuleIdentificer)
 	}
 
-	file, err := detectedModule.FileSystem().Open(detectedPath)
+	fileData, err := readModuleFile(detectedModule, importedPath, detectedPath)
+	// library paths are the last resort of local imports
+	if errors.Is(err, fs.ErrNotExist) && dependencyModuleIdentificer == "" && !utils.IsRelativeImport(importedPath) {
+		if jpathData, jpathFoundAt, found := importer.readFromJPaths(importedPath); found {
+			fileData, foundAt, err = jpathData, jpathFoundAt, nil
+		}
+	}
 	if err != nil {
 		return jsonnet.MakeContents(""), "", err
 	}
-	defer file.Close()
-	fileData, err := io.ReadAll(file)
+	return importer.transform(fileData, foundAt)
+}
+
+// importFromJPath imports importedPath from a file of a library path: next
+// to the file, then in the library paths.
+func (importer *GitImporter) importFromJPath(sourceModule custodian.Module, sourceFilePath, importedPath string) (jsonnet.Contents, string, error) {
+	detectedPath := path.Join(path.Dir(sourceFilePath), importedPath)
+	foundAt := utils.BuildFoundAtPath(sourceModule.Identifier(), detectedPath)
+	fileData, err := readModuleFile(sourceModule, importedPath, detectedPath)
+	if errors.Is(err, fs.ErrNotExist) && !utils.IsRelativeImport(importedPath) {
+		if jpathData, jpathFoundAt, found := importer.readFromJPaths(importedPath); found {
+			fileData, foundAt, err = jpathData, jpathFoundAt, nil
+		}
+	}
 	if err != nil {
 		return jsonnet.MakeContents(""), "", err
 	}
+	return importer.transform(fileData, foundAt)
+}
 
-	// Apply transformers
-	for _, transformer := range importer.Transformers {
-		fileData, err = transformer(foundAt, fileData)
-		if err != nil {
-			return jsonnet.MakeContents(""), "", err
+// readFromJPaths reads importedPath from the right-most library path having
+// it.
+func (importer *GitImporter) readFromJPaths(importedPath string) ([]byte, string, bool) {
+	for i := len(importer.JPaths) - 1; i >= 0; i-- {
+		jpathModule, _ := importer.jpathModule(importer.JPaths[i])
+		fileData, err := readModuleFile(jpathModule, importedPath, importedPath)
+		if err == nil {
+			return fileData, utils.BuildFoundAtPath(jpathModule.Identifier(), importedPath), true
 		}
 	}
+	return nil, "", false
+}
+
+// jpathModule returns the module of a library path, false if dir is not one
+// of the library paths.
+func (importer *GitImporter) jpathModule(dir string) (custodian.Module, bool) {
+	if !slices.Contains(importer.JPaths, dir) {
+		return nil, false
+	}
+	importer.jpathMu.Lock()
+	defer importer.jpathMu.Unlock()
+	if jpathModule, exists := importer.jpathModules[dir]; exists {
+		return jpathModule, true
+	}
+	if importer.jpathModules == nil {
+		importer.jpathModules = make(map[string]custodian.Module)
+	}
+	jpathModule := &module{
+		dependencies: map[string]string{},
+		fileSystem:   utils.DirFS(dir),
+		identifier:   dir,
+	}
+	importer.jpathModules[dir] = jpathModule
+	return jpathModule, true
+}
+
+// readModuleFile reads the file at detectedPath in module, the target of
+// importedPath.
+func readModuleFile(module custodian.Module, importedPath, detectedPath string) ([]byte, error) {
+	// module file systems are rooted at the module directory, imports may
+	// not leave it
+	if escapesModule(detectedPath) {
+		return nil, fmt.Errorf("import %q from %s: %s: %w", importedPath, module.Identifier(), detectedPath, utils.ErrEscapesRoot)
+	}
+	file, err := module.FileSystem().Open(detectedPath)
+	if errors.Is(err, utils.ErrEscapesRoot) {
+		return nil, fmt.Errorf("import %q from %s: %s: %w through a symlink", importedPath, module.Identifier(), detectedPath, utils.ErrEscapesRoot)
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// transform applies the transformers to fileData, the file found at foundAt,
+// or returns their cached output.
+func (importer *GitImporter) transform(fileData []byte, foundAt string) (jsonnet.Contents, string, error) {
+	key := importer.cacheKey(foundAt, fileData)
+	entry, cached := importer.Cache.get(key)
+	if cached {
+		importer.recordLocation(foundAt, entry.Transformers)
+		return jsonnet.MakeContentsRaw(entry.Data), foundAt, nil
+	}
+	entry, stored := importer.Cache.load(key)
+	if !stored {
+		entry = importCacheEntry{Data: fileData}
+		secret := false
+		for _, transformer := range importer.Transformers {
+			transformed, err := transformer.Transform(foundAt, entry.Data)
+			if err != nil {
+				return jsonnet.MakeContents(""), "", err
+			}
+			if !bytes.Equal(transformed, entry.Data) {
+				entry.Transformers = append(entry.Transformers, transformer.Name)
+				secret = secret || transformer.Secret
+			}
+			entry.Data = transformed
+		}
+		// secrets stay in memory
+		if len(entry.Transformers) > 0 && !secret {
+			importer.Cache.store(key, entry)
+		}
+	}
+	importer.Cache.put(key, entry)
+	importer.recordLocation(foundAt, entry.Transformers)
+	return jsonnet.MakeContentsRaw(entry.Data), foundAt, nil
+}
+
+// cacheKey returns the cache key of fileData, the file found at foundAt, for
+// the transformers of importer. Root modules and workspace modules of
+// importers with different roots share identifiers, so files key by their
+// location on disk.
+func (importer *GitImporter) cacheKey(foundAt string, fileData []byte) importCacheKey {
+	identities := make([]string, len(importer.Transformers))
+	for i, transformer := range importer.Transformers {
+		identities[i] = fmt.Sprintf("%q %q %t", transformer.Name, transformer.Config, transformer.Secret)
+	}
+	sum := sha256.Sum256(fileData)
+	return importCacheKey{
+		location:     importer.Location(foundAt, TracePathsCache),
+		transformers: strings.Join(identities, ","),
+		sum:          hex.EncodeToString(sum[:]),
+	}
+}
+
+// escapesModule reports whether filePath, relative to the root of a module,
+// leads outside of it.
+func escapesModule(filePath string) bool {
+	filePath = path.Clean(filePath)
+	return path.IsAbs(filePath) || filePath == ".." || strings.HasPrefix(filePath, "../")
+}
 
-	// Return the contents
-	contents = jsonnet.MakeContentsRaw(fileData)
-	return contents, foundAt, nil
+// AddTransformer adds a transformer named name, with the settings it was
+// built with in config, as described by ImportTransformer.
+func (importer *GitImporter) AddTransformer(name, config string, transformer Transformer) {
+	importer.Transformers = append(importer.Transformers, ImportTransformer{Name: name, Config: config, Transform: transformer})
 }
 
-func (importer *GitImporter) AddTransformer(transformer Transformer) {
-	importer.Transformers = append(importer.Transformers, transformer)
+// AddSecretTransformer adds a transformer whose output is secret, such as a
+// decryption, and is therefore never written to the cache directory.
+func (importer *GitImporter) AddSecretTransformer(name, config string, transformer Transformer) {
+	importer.Transformers = append(importer.Transformers, ImportTransformer{Name: name, Config: config, Transform: transformer, Secret: true})
 }
@@ -5,8 +5,12 @@ import (
 	"context"
 	"embed"
 	_ "embed"
+	"errors"
 	"io/fs"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
@@ -103,3 +107,464 @@ func TestGitImporter_Import(t *testing.T) {
 		})
 	}
 }
+
+// dirResolver resolves module identifiers to the directories of its map.
+type dirResolver map[string]string
+
+func (d dirResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	return NewModuleFromFS(moduleIdentifier, utils.DirFS(d[moduleIdentifier]))
+}
+
+func TestGitImporter_Import_moduleBoundaries(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"secret.libsonnet":      "{ secret: true }",
+		"app/custodian.json":    `{"require": {"lib": "lib@v1.0.0"}}`,
+		"app/main.jsonnet":      "{}",
+		"app/sub/a.libsonnet":   "{ a: 1 }",
+		"lib/main.libsonnet":    "{ lib: 1 }",
+		"lib/sub/b.libsonnet":   "{ b: 1 }",
+		"outside/c.libsonnet":   "{ c: 1 }",
+		"app/sub/d/e.libsonnet": "{ e: 1 }",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"app/linked.libsonnet":   "sub/a.libsonnet",
+		"app/escape.libsonnet":   "../secret.libsonnet",
+		"app/absolute.libsonnet": filepath.Join(dir, "secret.libsonnet"),
+		"app/outside":            "../outside",
+		"app/dangling.libsonnet": "sub/../../missing.libsonnet",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resolver := dirResolver{"app": filepath.Join(dir, "app"), "lib@v1.0.0": filepath.Join(dir, "lib")}
+	root, err := resolver.Resolve(context.Background(), "app")
+	if err != nil {
+		t.Fatalf("Failed to load root module: %v", err)
+	}
+	dependencyTree, err := NewDependencyTree(root, resolver)
+	if err != nil {
+		t.Fatalf("Failed to build dependency tree: %v", err)
+	}
+	importer := GitImporter{DependencyTree: dependencyTree}
+	fromApp := func(filePath string) string {
+		return "app" + utils.ModuleIdentifierSeparator + filePath
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		importedFrom string
+		importedPath string
+		want         string
+		wantEscape   bool
+	}{
+		{
+			name:         "relative import within the module",
+			importedFrom: fromApp("sub/d/e.libsonnet"),
+			importedPath: "../a.libsonnet",
+			want:         "{ a: 1 }",
+		},
+		{
+			name:         "relative import out of the module",
+			importedFrom: fromApp("main.jsonnet"),
+			importedPath: "../secret.libsonnet",
+			wantEscape:   true,
+		},
+		{
+			name:         "relative import climbing back into the module",
+			importedFrom: fromApp("sub/a.libsonnet"),
+			importedPath: "../../app/main.jsonnet",
+			wantEscape:   true,
+		},
+		{
+			name:         "relative import through a subdirectory",
+			importedFrom: fromApp("main.jsonnet"),
+			importedPath: "./sub/../../secret.libsonnet",
+			wantEscape:   true,
+		},
+		{
+			name:         "absolute import",
+			importedFrom: fromApp("main.jsonnet"),
+			importedPath: "/etc/passwd",
+			wantEscape:   true,
+		},
+		{
+			name:         "dependency import",
+			importedFrom: fromApp("main.jsonnet"),
+			importedPath: "lib/sub/b.libsonnet",
+			want:         "{ b: 1 }",
+		},
+		{
+			name:         "dependency import out of the dependency",
+			importedFrom: fromApp("main.jsonnet"),
+			importedPath: "lib/../app/main.jsonnet",
+			wantEscape:   true,
+		},
+		{
+			name:         "relative import out of a dependency",
+			importedFrom: "lib@v1.0.0" + utils.ModuleIdentifierSeparator + "main.libsonnet",
+			importedPath: "../secret.libsonnet",
+			wantEscape:   true,
+		},
+		{
+			name:         "symlink within the module",
+			importedFrom: fromApp("main.jsonnet"),
+			importedPath: "./linked.libsonnet",
+			want:         "{ a: 1 }",
+		},
+		{
+			name:         "relative symlink out of the module",
+			importedFrom: fromApp("main.jsonnet"),
+			importedPath: "./escape.libsonnet",
+			wantEscape:   true,
+		},
+		{
+			name:         "absolute symlink",
+			importedFrom: fromApp("main.jsonnet"),
+			importedPath: "./absolute.libsonnet",
+			wantEscape:   true,
+		},
+		{
+			name:         "dangling symlink out of the module",
+			importedFrom: fromApp("main.jsonnet"),
+			importedPath: "./dangling.libsonnet",
+			wantEscape:   true,
+		},
+		{
+			name:         "symlinked directory out of the module",
+			importedFrom: fromApp("main.jsonnet"),
+			importedPath: "./outside/c.libsonnet",
+			wantEscape:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, gotErr := importer.Import(tt.importedFrom, tt.importedPath)
+			if gotErr != nil {
+				if !tt.wantEscape || !errors.Is(gotErr, utils.ErrEscapesRoot) {
+					t.Errorf("Import() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantEscape {
+				t.Fatalf("Import() succeeded unexpectedly: %s", got.Data())
+			}
+			if string(got.Data()) != tt.want {
+				t.Errorf("Import() = %s, want %s", got.Data(), tt.want)
+			}
+		})
+	}
+}
+
+func TestGitImporter_Import_jpath(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app/custodian.json":                      `{"require": {"lib": "lib@v1.0.0"}}`,
+		"app/main.jsonnet":                        "{}",
+		"app/local.libsonnet":                     "{ local: 1 }",
+		"lib/main.libsonnet":                      "{ lib: 1 }",
+		"vendor/local.libsonnet":                  "{ vendored: 1 }",
+		"vendor/github.com/x/a/main.libsonnet":    "{ a: 1 }",
+		"vendor/github.com/x/a/util.libsonnet":    "{ util: 1 }",
+		"vendor/github.com/x/b/main.libsonnet":    "{ b: 1 }",
+		"override/github.com/x/b/main.libsonnet":  "{ b: 2 }",
+		"override/github.com/x/a/other.libsonnet": "{ other: 1 }",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resolver := dirResolver{"app": filepath.Join(dir, "app"), "lib@v1.0.0": filepath.Join(dir, "lib")}
+	root, err := resolver.Resolve(context.Background(), "app")
+	if err != nil {
+		t.Fatalf("Failed to load root module: %v", err)
+	}
+	dependencyTree, err := NewDependencyTree(root, resolver)
+	if err != nil {
+		t.Fatalf("Failed to build dependency tree: %v", err)
+	}
+	vendor, override := filepath.Join(dir, "vendor"), filepath.Join(dir, "override")
+	importer := GitImporter{DependencyTree: dependencyTree, JPaths: []string{vendor, override}}
+	fromApp := "app" + utils.ModuleIdentifierSeparator + "main.jsonnet"
+	fromVendor := vendor + utils.ModuleIdentifierSeparator + "github.com/x/a/main.libsonnet"
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		importedFrom string
+		importedPath string
+		want         string
+		wantFoundAt  string
+		wantErr      bool
+	}{
+		{
+			name:         "module file before library paths",
+			importedFrom: fromApp,
+			importedPath: "local.libsonnet",
+			want:         "{ local: 1 }",
+			wantFoundAt:  "app" + utils.ModuleIdentifierSeparator + "local.libsonnet",
+		},
+		{
+			name:         "dependency before library paths",
+			importedFrom: fromApp,
+			importedPath: "lib/main.libsonnet",
+			want:         "{ lib: 1 }",
+			wantFoundAt:  "lib@v1.0.0" + utils.ModuleIdentifierSeparator + "main.libsonnet",
+		},
+		{
+			name:         "library path",
+			importedFrom: fromApp,
+			importedPath: "github.com/x/a/main.libsonnet",
+			want:         "{ a: 1 }",
+			wantFoundAt:  fromVendor,
+		},
+		{
+			name:         "right-most library path wins",
+			importedFrom: fromApp,
+			importedPath: "github.com/x/b/main.libsonnet",
+			want:         "{ b: 2 }",
+			wantFoundAt:  override + utils.ModuleIdentifierSeparator + "github.com/x/b/main.libsonnet",
+		},
+		{
+			name:         "relative imports are not looked up in library paths",
+			importedFrom: fromApp,
+			importedPath: "./github.com/x/a/main.libsonnet",
+			wantErr:      true,
+		},
+		{
+			name:         "relative import from a library path",
+			importedFrom: fromVendor,
+			importedPath: "./util.libsonnet",
+			want:         "{ util: 1 }",
+			wantFoundAt:  vendor + utils.ModuleIdentifierSeparator + "github.com/x/a/util.libsonnet",
+		},
+		{
+			name:         "library path import from a library path",
+			importedFrom: fromVendor,
+			importedPath: "github.com/x/a/other.libsonnet",
+			want:         "{ other: 1 }",
+			wantFoundAt:  override + utils.ModuleIdentifierSeparator + "github.com/x/a/other.libsonnet",
+		},
+		{
+			name:         "relative import out of a library path",
+			importedFrom: fromVendor,
+			importedPath: "../../../../app/main.jsonnet",
+			wantErr:      true,
+		},
+		{
+			name:         "missing file",
+			importedFrom: fromApp,
+			importedPath: "github.com/x/c/main.libsonnet",
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotFoundAt, gotErr := importer.Import(tt.importedFrom, tt.importedPath)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("Import() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatalf("Import() succeeded unexpectedly: %s", got.Data())
+			}
+			if string(got.Data()) != tt.want || gotFoundAt != tt.wantFoundAt {
+				t.Errorf("Import() = %s, %v, want %s, %v", got.Data(), gotFoundAt, tt.want, tt.wantFoundAt)
+			}
+		})
+	}
+}
+
+func TestGitImporter_Import_entry(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app/common.libsonnet":         "{ common: 1 }",
+		"app/envs/prod/main.jsonnet":   "{ prod: 1 }",
+		"app/envs/prod/vars.libsonnet": "{ vars: 1 }",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resolver := dirResolver{".": filepath.Join(dir, "app")}
+	root, err := resolver.Resolve(context.Background(), ".")
+	if err != nil {
+		t.Fatalf("Failed to load root module: %v", err)
+	}
+	dependencyTree, err := NewDependencyTree(root, resolver)
+	if err != nil {
+		t.Fatalf("Failed to build dependency tree: %v", err)
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		entryFile    string
+		entryPath    string
+		importedFrom string
+		importedPath string
+		want         string
+		wantFoundAt  string
+	}{
+		{
+			name:         "entry file from a subdirectory",
+			entryFile:    "main.jsonnet",
+			entryPath:    "envs/prod/main.jsonnet",
+			importedPath: "main.jsonnet",
+			want:         "{ prod: 1 }",
+			wantFoundAt:  "." + utils.ModuleIdentifierSeparator + "envs/prod/main.jsonnet",
+		},
+		{
+			name:         "entry file from another directory",
+			entryFile:    "../app/envs/prod/main.jsonnet",
+			entryPath:    "envs/prod/main.jsonnet",
+			importedPath: "../app/envs/prod/main.jsonnet",
+			want:         "{ prod: 1 }",
+			wantFoundAt:  "." + utils.ModuleIdentifierSeparator + "envs/prod/main.jsonnet",
+		},
+		{
+			name:         "relative import of the entry file",
+			entryFile:    "main.jsonnet",
+			entryPath:    "envs/prod/main.jsonnet",
+			importedFrom: "." + utils.ModuleIdentifierSeparator + "envs/prod/main.jsonnet",
+			importedPath: "../../common.libsonnet",
+			want:         "{ common: 1 }",
+			wantFoundAt:  "." + utils.ModuleIdentifierSeparator + "common.libsonnet",
+		},
+		{
+			name:         "relative import of a snippet",
+			entryPath:    "envs/prod/<cmdline>",
+			importedPath: "./vars.libsonnet",
+			want:         "{ vars: 1 }",
+			wantFoundAt:  "." + utils.ModuleIdentifierSeparator + "envs/prod/vars.libsonnet",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			importer := GitImporter{DependencyTree: dependencyTree, EntryFile: tt.entryFile, EntryPath: tt.entryPath}
+			got, gotFoundAt, gotErr := importer.Import(tt.importedFrom, tt.importedPath)
+			if gotErr != nil {
+				t.Fatalf("Import() failed: %v", gotErr)
+			}
+			if string(got.Data()) != tt.want || gotFoundAt != tt.wantFoundAt {
+				t.Errorf("Import() = %s, %v, want %s, %v", got.Data(), gotFoundAt, tt.want, tt.wantFoundAt)
+			}
+		})
+	}
+}
+
+// upperTransformer upper-cases the files with the .upper extension.
+func upperTransformer(foundAt string, data []byte) ([]byte, error) {
+	if !strings.HasSuffix(foundAt, ".upper") {
+		return data, nil
+	}
+	return bytes.ToUpper(data), nil
+}
+
+func TestGitImporter_Import_trace(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app/custodian.json":  `{"require": {"lib": "lib@v1.0.0"}}`,
+		"app/main.jsonnet":    "{}",
+		"app/text.upper":      "text",
+		"lib/main.libsonnet":  "{ lib: 1 }",
+		"lib/custodian.json":  "{}",
+		"vendor/v.libsonnet":  "{ v: 1 }",
+		"lib/other.libsonnet": "{ other: 1 }",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resolver := dirResolver{".": filepath.Join(dir, "app"), "lib@v1.0.0": filepath.Join(dir, "lib")}
+	root, err := resolver.Resolve(context.Background(), ".")
+	if err != nil {
+		t.Fatalf("Failed to load root module: %v", err)
+	}
+	dependencyTree, err := NewDependencyTree(root, resolver)
+	if err != nil {
+		t.Fatalf("Failed to build dependency tree: %v", err)
+	}
+	vendorDir := filepath.Join(dir, "vendor")
+	mainAt := utils.BuildFoundAtPath(".", "main.jsonnet")
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		importedFrom string
+		importedPath string
+		want         string
+	}{
+		{
+			name:         "entry file",
+			importedPath: "main.jsonnet",
+			want:         "IMPORT: \"main.jsonnet\": module ., path main.jsonnet\n",
+		},
+		{
+			name:         "dependency",
+			importedFrom: mainAt,
+			importedPath: "lib/main.libsonnet",
+			want:         "IMPORT: \"lib/main.libsonnet\" from main.jsonnet: module lib@v1.0.0, path main.libsonnet\n",
+		},
+		{
+			name:         "transformed file",
+			importedFrom: mainAt,
+			importedPath: "./text.upper",
+			want:         "IMPORT: \"./text.upper\" from main.jsonnet: module ., path text.upper, transformers upper\n",
+		},
+		{
+			name:         "library path",
+			importedFrom: mainAt,
+			importedPath: "v.libsonnet",
+			want:         "IMPORT: \"v.libsonnet\" from main.jsonnet: module " + vendorDir + ", path v.libsonnet\n",
+		},
+		{
+			name:         "missing file",
+			importedFrom: utils.BuildFoundAtPath("lib@v1.0.0", "main.libsonnet"),
+			importedPath: "./missing.libsonnet",
+			want:         "IMPORT: \"./missing.libsonnet\" from lib@v1.0.0/main.libsonnet: open missing.libsonnet: no such file or directory\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trace bytes.Buffer
+			importer := GitImporter{DependencyTree: dependencyTree, JPaths: []string{vendorDir}, TraceOut: &trace}
+			importer.AddTransformer("upper", "", upperTransformer)
+			importer.Import(tt.importedFrom, tt.importedPath)
+			if got := trace.String(); got != tt.want {
+				t.Errorf("Import() traced %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
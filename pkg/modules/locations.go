@@ -0,0 +1,123 @@
+package modules
+
+import (
+	"cmp"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
+)
+
+// How import locations read in errors and traces.
+const (
+	// TracePathsModule shows the path in the module after its identifier,
+	// like github.com/org/lib@v1.2.0/path.libsonnet.
+	TracePathsModule = "module"
+	// TracePathsCache shows the path of the file on disk, in the module
+	// cache for remote modules.
+	TracePathsCache = "cache"
+)
+
+// Location returns how the location foundAt, returned by Import, reads in
+// errors and traces for tracePaths.
+func (importer *GitImporter) Location(foundAt, tracePaths string) string {
+	if tracePaths == TracePathsCache {
+		moduleIdentifier, filePath := utils.ParseImportedFrom(foundAt)
+		module, exists := importer.DependencyTree.GetModule(moduleIdentifier)
+		if !exists {
+			module, exists = importer.jpathModule(moduleIdentifier)
+		}
+		if exists {
+			if dir, ok := utils.FSDir(module.FileSystem()); ok {
+				return filepath.Join(dir, filepath.FromSlash(filePath))
+			}
+		}
+	}
+	return utils.DisplayFoundAtPath(foundAt)
+}
+
+// DisplayLocations replaces the locations returned by Import in text, such
+// as a formatted error, with how they read for tracePaths.
+func (importer *GitImporter) DisplayLocations(text, tracePaths string) string {
+	locations := importer.recordedLocations()
+	// longest first, for locations prefixing others
+	slices.SortFunc(locations, func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	replacements := make([]string, 0, 2*len(locations))
+	for _, foundAt := range locations {
+		replacements = append(replacements, foundAt, importer.Location(foundAt, tracePaths))
+	}
+	return strings.NewReplacer(replacements...).Replace(text)
+}
+
+// Dependencies returns the files imported so far, sorted, as they read for
+// tracePaths.
+func (importer *GitImporter) Dependencies(tracePaths string) []string {
+	dependencies := importer.recordedLocations()
+	for i, foundAt := range dependencies {
+		dependencies[i] = importer.Location(foundAt, tracePaths)
+	}
+	slices.Sort(dependencies)
+	return slices.Compact(dependencies)
+}
+
+// displayLocation returns the location foundAt as it reads for TracePaths,
+// remembering it for displayedLocation. Locations displaying like another
+// one, such as a directory of the root module named after a dependency, are
+// left alone.
+func (importer *GitImporter) displayLocation(foundAt string) string {
+	if importer.TracePaths == "" {
+		return foundAt
+	}
+	location := importer.Location(foundAt, importer.TracePaths)
+	importer.locationsMu.Lock()
+	defer importer.locationsMu.Unlock()
+	if displayed, exists := importer.displayed[location]; exists && displayed != foundAt {
+		return foundAt
+	}
+	if importer.displayed == nil {
+		importer.displayed = make(map[string]string)
+	}
+	importer.displayed[location] = foundAt
+	return location
+}
+
+// displayedLocation returns the location displayed by location, a location
+// returned by Import.
+func (importer *GitImporter) displayedLocation(location string) string {
+	importer.locationsMu.Lock()
+	defer importer.locationsMu.Unlock()
+	if foundAt, exists := importer.displayed[location]; exists {
+		return foundAt
+	}
+	return location
+}
+
+// recordLocation remembers foundAt, and the transformers applied to its
+// file, for DisplayLocations and Dependencies.
+func (importer *GitImporter) recordLocation(foundAt string, applied []string) {
+	importer.locationsMu.Lock()
+	defer importer.locationsMu.Unlock()
+	if importer.locations == nil {
+		importer.locations = make(map[string][]string)
+	}
+	importer.locations[foundAt] = applied
+}
+
+// recordedLocations returns the locations recorded by recordLocation.
+func (importer *GitImporter) recordedLocations() []string {
+	importer.locationsMu.Lock()
+	defer importer.locationsMu.Unlock()
+	return slices.Collect(maps.Keys(importer.locations))
+}
+
+// appliedTransformers returns the transformers applied to the file found at
+// foundAt.
+func (importer *GitImporter) appliedTransformers(foundAt string) []string {
+	importer.locationsMu.Lock()
+	defer importer.locationsMu.Unlock()
+	return importer.locations[foundAt]
+}
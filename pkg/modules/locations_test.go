@@ -0,0 +1,234 @@
+package modules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
+	"github.com/google/go-jsonnet"
+)
+
+func TestGitImporter_DisplayLocations(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app/custodian.json":  `{"require": {"lib": "github.com/org/lib@v1.2.0"}}`,
+		"app/main.jsonnet":    "{}",
+		"lib/path.libsonnet":  "{ lib: 1 }",
+		"vendor/v.libsonnet":  "{ v: 1 }",
+		"lib/other.libsonnet": "{ other: 1 }",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resolver := dirResolver{".": filepath.Join(dir, "app"), "github.com/org/lib@v1.2.0": filepath.Join(dir, "lib")}
+	root, err := resolver.Resolve(context.Background(), ".")
+	if err != nil {
+		t.Fatalf("Failed to load root module: %v", err)
+	}
+	dependencyTree, err := NewDependencyTree(root, resolver)
+	if err != nil {
+		t.Fatalf("Failed to build dependency tree: %v", err)
+	}
+	vendorDir := filepath.Join(dir, "vendor")
+	importer := GitImporter{DependencyTree: dependencyTree, JPaths: []string{vendorDir}}
+	for _, importedPath := range []string{"main.jsonnet", "lib/path.libsonnet", "v.libsonnet"} {
+		if _, _, err := importer.Import("", importedPath); err != nil {
+			t.Fatalf("Import(%q) failed: %v", importedPath, err)
+		}
+	}
+	libAt := utils.BuildFoundAtPath("github.com/org/lib@v1.2.0", "path.libsonnet")
+	text := "RUNTIME ERROR: boom\n\t" + libAt + ":1:3-7\tobject <anonymous>\n" +
+		"\t" + utils.BuildFoundAtPath(".", "main.jsonnet") + ":1:1-3\t\n" +
+		"\t" + utils.BuildFoundAtPath(vendorDir, "v.libsonnet") + ":1:1-3\t\n"
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		tracePaths string
+		want       string
+	}{
+		{
+			name:       "module paths",
+			tracePaths: TracePathsModule,
+			want: "RUNTIME ERROR: boom\n\tgithub.com/org/lib@v1.2.0/path.libsonnet:1:3-7\tobject <anonymous>\n" +
+				"\tmain.jsonnet:1:1-3\t\n" +
+				"\t" + vendorDir + "/v.libsonnet:1:1-3\t\n",
+		},
+		{
+			name:       "cache paths",
+			tracePaths: TracePathsCache,
+			want: "RUNTIME ERROR: boom\n\t" + filepath.Join(dir, "lib", "path.libsonnet") + ":1:3-7\tobject <anonymous>\n" +
+				"\t" + filepath.Join(dir, "app", "main.jsonnet") + ":1:1-3\t\n" +
+				"\t" + filepath.Join(vendorDir, "v.libsonnet") + ":1:1-3\t\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := importer.DisplayLocations(text, tt.tracePaths)
+			if got != tt.want {
+				t.Errorf("DisplayLocations() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// locations not imported are left alone
+	otherAt := utils.BuildFoundAtPath("github.com/org/lib@v1.2.0", "other.libsonnet")
+	if got := importer.DisplayLocations(otherAt, TracePathsModule); got != otherAt {
+		t.Errorf("DisplayLocations() = %q, want %q", got, otherAt)
+	}
+}
+
+func TestGitImporter_Dependencies(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app/custodian.json":   `{"require": {"lib": "github.com/org/lib@v1.2.0"}}`,
+		"app/main.jsonnet":     "{}",
+		"app/data.json":        "{}",
+		"lib/path.libsonnet":   "{ lib: 1 }",
+		"lib/unused.libsonnet": "{ unused: 1 }",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resolver := dirResolver{".": filepath.Join(dir, "app"), "github.com/org/lib@v1.2.0": filepath.Join(dir, "lib")}
+	root, err := resolver.Resolve(context.Background(), ".")
+	if err != nil {
+		t.Fatalf("Failed to load root module: %v", err)
+	}
+	dependencyTree, err := NewDependencyTree(root, resolver)
+	if err != nil {
+		t.Fatalf("Failed to build dependency tree: %v", err)
+	}
+	importer := GitImporter{DependencyTree: dependencyTree}
+	mainAt := utils.BuildFoundAtPath(".", "main.jsonnet")
+	imports := [][2]string{
+		{"", "main.jsonnet"},
+		{mainAt, "lib/path.libsonnet"},
+		{mainAt, "./data.json"},
+		{mainAt, "./data.json"},
+		{mainAt, "./missing.json"},
+	}
+	for _, imported := range imports {
+		importer.Import(imported[0], imported[1])
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		tracePaths string
+		want       []string
+	}{
+		{
+			name:       "module paths",
+			tracePaths: TracePathsModule,
+			want:       []string{"data.json", "github.com/org/lib@v1.2.0/path.libsonnet", "main.jsonnet"},
+		},
+		{
+			name:       "cache paths",
+			tracePaths: TracePathsCache,
+			want: []string{
+				filepath.Join(dir, "app", "data.json"),
+				filepath.Join(dir, "app", "main.jsonnet"),
+				filepath.Join(dir, "lib", "path.libsonnet"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := importer.Dependencies(tt.tracePaths)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Dependencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitImporter_Import_tracePaths(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app/custodian.json":  `{"require": {"lib": "github.com/org/lib@v1.2.0"}}`,
+		"app/main.jsonnet":    `{ this: std.thisFile, lib: import "lib/path.libsonnet" }`,
+		"lib/path.libsonnet":  `{ this: std.thisFile, other: import "other.libsonnet" }`,
+		"lib/other.libsonnet": "std.thisFile",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resolver := dirResolver{".": filepath.Join(dir, "app"), "github.com/org/lib@v1.2.0": filepath.Join(dir, "lib")}
+	root, err := resolver.Resolve(context.Background(), ".")
+	if err != nil {
+		t.Fatalf("Failed to load root module: %v", err)
+	}
+	dependencyTree, err := NewDependencyTree(root, resolver)
+	if err != nil {
+		t.Fatalf("Failed to build dependency tree: %v", err)
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		tracePaths string
+		want       string
+	}{
+		{
+			name: "module locations",
+			want: `{"lib":{"other":"github.com/org/lib@v1.2.0:mod-sep:other.libsonnet","this":"github.com/org/lib@v1.2.0:mod-sep:path.libsonnet"},"this":".:mod-sep:main.jsonnet"}`,
+		},
+		{
+			name:       "module paths",
+			tracePaths: TracePathsModule,
+			want:       `{"lib":{"other":"github.com/org/lib@v1.2.0/other.libsonnet","this":"github.com/org/lib@v1.2.0/path.libsonnet"},"this":"main.jsonnet"}`,
+		},
+		{
+			name:       "cache paths",
+			tracePaths: TracePathsCache,
+			want: fmt.Sprintf(`{"lib":{"other":%q,"this":%q},"this":%q}`,
+				filepath.Join(dir, "lib", "other.libsonnet"), filepath.Join(dir, "lib", "path.libsonnet"), filepath.Join(dir, "app", "main.jsonnet")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			importer := &GitImporter{DependencyTree: dependencyTree, TracePaths: tt.tracePaths}
+			vm := jsonnet.MakeVM()
+			vm.Importer(importer)
+			got, err := vm.EvaluateFile("main.jsonnet")
+			if err != nil {
+				t.Fatalf("EvaluateFile() failed: %v", err)
+			}
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, []byte(got)); err != nil {
+				t.Fatal(err)
+			}
+			if compact.String() != tt.want {
+				t.Errorf("EvaluateFile() = %s, want %s", compact.String(), tt.want)
+			}
+			if deps := importer.Dependencies(TracePathsModule); len(deps) != 3 {
+				t.Errorf("Dependencies() = %v, want 3 files", deps)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	LockFileName = "module.lock"
+	// VendorDirName is the directory of the root module keeping the
+	// archives of its locked modules.
+	VendorDirName = "custodian.vendor"
+)
+
+// LockFile is the content of a module.lock file: every non-local module of
+// the dependency tree with the hash of its content, sorted by identifier.
+type LockFile struct {
+	Modules []LockEntry `json:"modules"`
+}
+
+// LockEntry records a module and its hash, as computed by modzip.HashFS.
+// Digest pins the artifact a mutable identifier, such as an OCI tag, was
+// resolved to, Branch the branch a module pinned to a commit tracks, and
+// Signer the key its signature was verified with.
+type LockEntry struct {
+	Module string `json:"module"`
+	Hash   string `json:"hash"`
+	Digest string `json:"digest,omitempty"`
+	Branch string `json:"branch,omitempty"`
+	Signer string `json:"signer,omitempty"`
+}
+
+// Lookup returns the entry of the given module identifier, if any. Entries
+// without hash, migrated from the legacy format, do not lock their module.
+func (l *LockFile) Lookup(moduleIdentifier string) (LockEntry, bool) {
+	if l == nil {
+		return LockEntry{}, false
+	}
+	for _, entry := range l.Modules {
+		if entry.Module == moduleIdentifier && entry.Hash != "" {
+			return entry, true
+		}
+	}
+	return LockEntry{}, false
+}
+
+// LoadLockFile reads the lock file in dir. It returns nil if there is none.
+func LoadLockFile(dir string) (*LockFile, error) {
+	lockFile, err := os.Open(filepath.Join(dir, LockFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer lockFile.Close()
+	lockData, err := ParseLockFile(lockFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", lockFile.Name(), err)
+	}
+	return lockData, nil
+}
+
+// ParseLockFile reads a lock file. The legacy format, a list of module
+// identifiers, is migrated to entries without hash.
+func ParseLockFile(lockFile fs.File) (*LockFile, error) {
+	var content json.RawMessage
+	if err := json.NewDecoder(lockFile).Decode(&content); err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		var moduleIdentifiers []string
+		if err := json.Unmarshal(trimmed, &moduleIdentifiers); err != nil {
+			return nil, err
+		}
+		lockData := &LockFile{Modules: make([]LockEntry, 0, len(moduleIdentifiers))}
+		for _, moduleIdentifier := range moduleIdentifiers {
+			lockData.Modules = append(lockData.Modules, LockEntry{Module: moduleIdentifier})
+		}
+		return lockData, nil
+	}
+	lockData := &LockFile{}
+	if err := json.Unmarshal(content, lockData); err != nil {
+		return nil, err
+	}
+	return lockData, nil
+}
+
+func SerializeLockFile(lockData *LockFile) ([]byte, error) {
+	data, err := json.MarshalIndent(lockData, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,65 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadLockFile(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		content    string
+		want       *LockFile
+		wantLocked bool
+		wantErr    bool
+	}{
+		{
+			name:       "lock file",
+			content:    `{"modules": [{"module": "github.com/org/lib@v1.0.0", "hash": "h1:abc"}]}`,
+			want:       &LockFile{Modules: []LockEntry{{Module: "github.com/org/lib@v1.0.0", Hash: "h1:abc"}}},
+			wantLocked: true,
+		},
+		{
+			name:    "legacy list of identifiers",
+			content: "[\n  \"github.com/org/lib@v1.0.0\"\n]",
+			want:    &LockFile{Modules: []LockEntry{{Module: "github.com/org/lib@v1.0.0"}}},
+		},
+		{
+			name: "no lock file",
+		},
+		{
+			name:    "invalid lock file",
+			content: `{"modules": "github.com/org/lib@v1.0.0"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.content != "" {
+				if err := os.WriteFile(filepath.Join(dir, LockFileName), []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, gotErr := LoadLockFile(dir)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("LoadLockFile() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("LoadLockFile() succeeded unexpectedly")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadLockFile() = %v, want %v", got, tt.want)
+			}
+			if _, locked := got.Lookup("github.com/org/lib@v1.0.0"); locked != tt.wantLocked {
+				t.Errorf("Lookup() locked = %v, want %v", locked, tt.wantLocked)
+			}
+		})
+	}
+}
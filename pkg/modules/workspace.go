@@ -0,0 +1,144 @@
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	WorkFileName = "custodian.work"
+)
+
+// WorkFile is the content of a custodian.work file. Each entry in Use is a
+// directory, relative to the work file, containing a module that replaces
+// any remote with the same module name across the whole dependency tree.
+type WorkFile struct {
+	Use []string `json:"use"`
+}
+
+// Workspace maps module names to the local directories that replace them.
+type Workspace struct {
+	Dir     string            // directory containing the work file
+	Modules map[string]string // module name -> absolute module directory
+}
+
+// Lookup returns the local directory replacing the given module name, if any.
+func (w *Workspace) Lookup(moduleName string) (string, bool) {
+	if w == nil {
+		return "", false
+	}
+	dir, exists := w.Modules[moduleName]
+	return dir, exists
+}
+
+// FindWorkFile looks for a work file in dir and its parents and returns its
+// path, or an empty string if there is none.
+func FindWorkFile(dir string) (string, error) {
+	return findFileUpward(dir, WorkFileName)
+}
+
+// FindModuleDir looks for a module file in dir and its parents and returns
+// the directory of the nearest one, or an empty string if there is none.
+func FindModuleDir(dir string) (string, error) {
+	moduleFilePath, err := findFileUpward(dir, ModuleFileName)
+	if err != nil || moduleFilePath == "" {
+		return "", err
+	}
+	return filepath.Dir(moduleFilePath), nil
+}
+
+// findFileUpward looks for the file fileName in dir and its parents and
+// returns its absolute path, or an empty string if there is none.
+func findFileUpward(dir, fileName string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		filePath := filepath.Join(dir, fileName)
+		if info, err := os.Stat(filePath); err == nil && !info.IsDir() {
+			return filePath, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadWorkspace reads the work file at workFilePath and the module file of
+// every directory it uses.
+func LoadWorkspace(workFilePath string) (*Workspace, error) {
+	// module directories do not depend on the current directory
+	workFilePath, err := filepath.Abs(workFilePath)
+	if err != nil {
+		return nil, err
+	}
+	workFile, err := os.Open(workFilePath)
+	if err != nil {
+		return nil, err
+	}
+	workData, err := ParseWorkFile(workFile)
+	workFile.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse work file %s: %w", workFilePath, err)
+	}
+
+	workspace := &Workspace{
+		Dir:     filepath.Dir(workFilePath),
+		Modules: make(map[string]string),
+	}
+	for _, use := range workData.Use {
+		moduleDir := use
+		if !filepath.IsAbs(moduleDir) {
+			moduleDir = filepath.Join(workspace.Dir, moduleDir)
+		}
+		moduleName, err := ReadModuleName(moduleDir)
+		if err != nil {
+			return nil, err
+		}
+		if previous, exists := workspace.Modules[moduleName]; exists {
+			return nil, fmt.Errorf("module %s is used twice in %s: %s and %s", moduleName, workFilePath, previous, moduleDir)
+		}
+		workspace.Modules[moduleName] = moduleDir
+	}
+	return workspace, nil
+}
+
+// ReadModuleName returns the module name declared in the module file of dir.
+func ReadModuleName(dir string) (string, error) {
+	moduleFile, err := os.Open(filepath.Join(dir, ModuleFileName))
+	if err != nil {
+		return "", fmt.Errorf("directory %s is not a module: %w", dir, err)
+	}
+	defer moduleFile.Close()
+
+	moduleData, err := ParseModuleFile(moduleFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse module file in %s: %w", dir, err)
+	}
+	if moduleData.Module == "" {
+		return "", fmt.Errorf("module file in %s has no module name", dir)
+	}
+	return moduleData.Module, nil
+}
+
+func ParseWorkFile(workFile fs.File) (*WorkFile, error) {
+	workData := &WorkFile{}
+	if err := json.NewDecoder(workFile).Decode(workData); err != nil {
+		return nil, err
+	}
+	return workData, nil
+}
+
+func SerializeWorkFile(workData *WorkFile) ([]byte, error) {
+	data, err := json.MarshalIndent(workData, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
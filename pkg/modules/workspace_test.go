@@ -0,0 +1,136 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestModule(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create module dir: %v", err)
+	}
+	data, err := SerializeModuleFile(&ModuleFile{Module: name, Require: map[string]string{}})
+	if err != nil {
+		t.Fatalf("Failed to serialize module file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ModuleFileName), data, 0644); err != nil {
+		t.Fatalf("Failed to write module file: %v", err)
+	}
+}
+
+func TestLoadWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	writeTestModule(t, filepath.Join(dir, "lib-a"), "github.com/org/lib-a")
+	writeTestModule(t, filepath.Join(dir, "libs", "lib-b"), "github.com/org/lib-b")
+
+	data, err := SerializeWorkFile(&WorkFile{Use: []string{"./lib-a", "./libs/lib-b"}})
+	if err != nil {
+		t.Fatalf("Failed to serialize work file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, WorkFileName), data, 0644); err != nil {
+		t.Fatalf("Failed to write work file: %v", err)
+	}
+
+	workFilePath, err := FindWorkFile(filepath.Join(dir, "libs", "lib-b"))
+	if err != nil {
+		t.Fatalf("FindWorkFile() failed: %v", err)
+	}
+	if workFilePath != filepath.Join(dir, WorkFileName) {
+		t.Fatalf("FindWorkFile() = %v, want %v", workFilePath, filepath.Join(dir, WorkFileName))
+	}
+
+	workspace, err := LoadWorkspace(workFilePath)
+	if err != nil {
+		t.Fatalf("LoadWorkspace() failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string // description of this test case
+		moduleName string
+		want       string
+		wantFound  bool
+	}{
+		{
+			name:       "module in workspace root",
+			moduleName: "github.com/org/lib-a",
+			want:       filepath.Join(dir, "lib-a"),
+			wantFound:  true,
+		},
+		{
+			name:       "module in workspace subdirectory",
+			moduleName: "github.com/org/lib-b",
+			want:       filepath.Join(dir, "libs", "lib-b"),
+			wantFound:  true,
+		},
+		{
+			name:       "module not in workspace",
+			moduleName: "github.com/org/lib-c",
+			want:       "",
+			wantFound:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotFound := workspace.Lookup(tt.moduleName)
+			if gotFound != tt.wantFound {
+				t.Errorf("Lookup() found = %v, want %v", gotFound, tt.wantFound)
+			}
+			if got != tt.want {
+				t.Errorf("Lookup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindModuleDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTestModule(t, filepath.Join(dir, "app"), "github.com/org/app")
+	writeTestModule(t, filepath.Join(dir, "app", "vendor", "lib"), "github.com/org/lib")
+	if err := os.MkdirAll(filepath.Join(dir, "app", "envs", "prod"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "other"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		dir  string
+		want string
+	}{
+		{
+			name: "module directory",
+			dir:  filepath.Join(dir, "app"),
+			want: filepath.Join(dir, "app"),
+		},
+		{
+			name: "subdirectory of a module",
+			dir:  filepath.Join(dir, "app", "envs", "prod"),
+			want: filepath.Join(dir, "app"),
+		},
+		{
+			name: "nearest module wins",
+			dir:  filepath.Join(dir, "app", "vendor", "lib"),
+			want: filepath.Join(dir, "app", "vendor", "lib"),
+		},
+		{
+			name: "outside of any module",
+			dir:  filepath.Join(dir, "other"),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := FindModuleDir(tt.dir)
+			if gotErr != nil {
+				t.Fatalf("FindModuleDir() failed: %v", gotErr)
+			}
+			if got != tt.want {
+				t.Errorf("FindModuleDir() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,354 @@
+// Package modzip implements the module archive format: a zip file holding the
+// files of a module under a <module identifier>/ prefix.
+//
+// Archives are deterministic: entries are sorted, have fixed timestamps and
+// permissions, and only regular files are included, so the same module
+// content always produces the same archive. The module hash is computed over
+// the same file list as the archive, so a module hashes identically whether
+// it is read from an archive, a directory or any other file system.
+package modzip
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	goModule "golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+const (
+	// MaxZipFile is the maximum size of a module archive.
+	MaxZipFile = 500 << 20
+	// MaxFileSize is the maximum uncompressed size of a file in a module.
+	MaxFileSize = 100 << 20
+)
+
+// fixedModTime is the modification time of every archive entry.
+var fixedModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ErrInvalidFile is returned for files that cannot be part of a module.
+var ErrInvalidFile = errors.New("invalid module file")
+
+// ignoredDirs are not part of modules.
+var ignoredDirs = []string{".git", ".hg", ".svn"}
+
+// Files returns the sorted paths of the files of the module in fsys. It fails
+// on symlinks, irregular files and files with invalid names.
+func Files(fsys fs.FS) ([]string, error) {
+	files, _, err := walk(fsys, false)
+	return files, err
+}
+
+// walk returns the sorted paths of the files of the module in fsys, and its
+// symlinks if withLinks, failing on them otherwise.
+func walk(fsys fs.FS, withLinks bool) (files, links []string, err error) {
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name != "." && slices.Contains(ignoredDirs, d.Name()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 && !withLinks {
+			return fmt.Errorf("%s: %w: symlinks are not allowed", name, ErrInvalidFile)
+		}
+		if d.Type()&fs.ModeSymlink == 0 && !d.Type().IsRegular() {
+			return fmt.Errorf("%s: %w: not a regular file", name, ErrInvalidFile)
+		}
+		if err := CheckFilePath(name); err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			links = append(links, name)
+		} else {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	slices.Sort(files)
+	slices.Sort(links)
+	return files, links, nil
+}
+
+// CheckFilePath reports whether name is a valid module file path: a clean,
+// relative, slash separated path without . or .. elements.
+func CheckFilePath(name string) error {
+	if !fs.ValidPath(name) || name == "." || strings.Contains(name, `\`) {
+		return fmt.Errorf("%s: %w: invalid path", name, ErrInvalidFile)
+	}
+	if err := goModule.CheckFilePath(name); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+	return nil
+}
+
+// Create writes the archive of the module in fsys to w.
+func Create(w io.Writer, moduleIdentifier string, fsys fs.FS) error {
+	files, err := Files(fsys)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	prefix := moduleIdentifier + "/"
+	var total int64
+	for _, name := range files {
+		data, err := readFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if total += int64(len(data)); total > MaxZipFile {
+			return fmt.Errorf("module %s is larger than %d bytes", moduleIdentifier, MaxZipFile)
+		}
+		header := &zip.FileHeader{
+			Name:     prefix + name,
+			Method:   zip.Deflate,
+			Modified: fixedModTime,
+		}
+		header.SetMode(0644)
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// CreateFromDir writes the archive of the module in dir to w.
+func CreateFromDir(w io.Writer, moduleIdentifier string, dir string) error {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	return Create(w, moduleIdentifier, root.FS())
+}
+
+// Extract extracts the archive of the module read from r into targetDir,
+// checking every entry. The archive is extracted into a temporary directory
+// next to targetDir and then renamed, so a failure never leaves a partial
+// module behind.
+func Extract(r io.Reader, moduleIdentifier string, targetDir string) error {
+	if err := os.MkdirAll(filepath.Dir(targetDir), 0755); err != nil {
+		return err
+	}
+	zipFile, err := os.CreateTemp(filepath.Dir(targetDir), ".zip-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(zipFile.Name())
+	defer zipFile.Close()
+
+	size, err := io.Copy(zipFile, io.LimitReader(r, MaxZipFile+1))
+	if err != nil {
+		return err
+	}
+	if size > MaxZipFile {
+		return fmt.Errorf("module archive larger than %d bytes", MaxZipFile)
+	}
+	zipReader, err := zip.NewReader(zipFile, size)
+	if err != nil {
+		return err
+	}
+	files, err := checkZip(zipReader, moduleIdentifier)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(targetDir), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return err
+	}
+
+	for name, file := range files {
+		if err := extractFile(file, filepath.Join(tmpDir, filepath.FromSlash(name))); err != nil {
+			return err
+		}
+	}
+
+	os.RemoveAll(targetDir) // ensure target dir is clean
+	return os.Rename(tmpDir, targetDir)
+}
+
+// OpenFS returns the files of the module archive read from r, checking
+// every entry as Extract does, without extracting them.
+func OpenFS(r io.ReaderAt, size int64, moduleIdentifier string) (fs.FS, error) {
+	if size > MaxZipFile {
+		return nil, fmt.Errorf("module archive larger than %d bytes", MaxZipFile)
+	}
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files, err := checkZip(zipReader, moduleIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	// the reader indexes its entries on the first Open: without their
+	// prefix, they are the files of the module
+	for name, file := range files {
+		file.Name = name
+	}
+	return zipReader, nil
+}
+
+// HashZip returns the hash of the module archive read from r.
+func HashZip(r io.ReaderAt, size int64, moduleIdentifier string) (string, error) {
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", err
+	}
+	files, err := checkZip(zipReader, moduleIdentifier)
+	if err != nil {
+		return "", err
+	}
+	entries := make(map[string]func() (io.ReadCloser, error), len(files))
+	for name, file := range files {
+		entries[name] = file.Open
+	}
+	return hash(moduleIdentifier, entries)
+}
+
+// HashFS returns the hash of the module in fsys, "h1:" followed by the
+// base64 SHA-256 of the sorted "<sha256>  <identifier>/<file>" lines of its
+// files, as recorded by Go in go.sum files.
+//
+// Archives cannot hold symlinks, but modules cloned from git may. A symlink
+// hashes as a "<sha256 of target>  <identifier>/<link> -> <target>" line,
+// which no file can produce since '>' is not allowed in file paths. fsys must
+// implement fs.ReadLinkFS for modules with symlinks.
+func HashFS(moduleIdentifier string, fsys fs.FS) (string, error) {
+	files, links, err := walk(fsys, true)
+	if err != nil {
+		return "", err
+	}
+	entries := make(map[string]func() (io.ReadCloser, error), len(files)+len(links))
+	for _, name := range files {
+		entries[name] = func() (io.ReadCloser, error) {
+			data, err := readFile(fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+	for _, name := range links {
+		target, err := fs.ReadLink(fsys, name)
+		if err != nil {
+			return "", err
+		}
+		target = filepath.ToSlash(target)
+		entries[name+symlinkSeparator+target] = func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(target)), nil
+		}
+	}
+	return hash(moduleIdentifier, entries)
+}
+
+// symlinkSeparator separates symlinks from their target in hashed names.
+const symlinkSeparator = " -> "
+
+// hash returns the hash of the entries of a module, opened by their function.
+func hash(moduleIdentifier string, entries map[string]func() (io.ReadCloser, error)) (string, error) {
+	prefix := moduleIdentifier + "/"
+	prefixed := make([]string, 0, len(entries))
+	for name := range entries {
+		prefixed = append(prefixed, prefix+name)
+	}
+	return dirhash.Hash1(prefixed, func(name string) (io.ReadCloser, error) {
+		return entries[strings.TrimPrefix(name, prefix)]()
+	})
+}
+
+// checkZip checks the entries of an archive and returns them by module path.
+func checkZip(zipReader *zip.Reader, moduleIdentifier string) (map[string]*zip.File, error) {
+	prefix := moduleIdentifier + "/"
+	files := make(map[string]*zip.File, len(zipReader.File))
+	folded := make(map[string]string, len(zipReader.File))
+	for _, file := range zipReader.File {
+		name, found := strings.CutPrefix(file.Name, prefix)
+		if !found {
+			return nil, fmt.Errorf("%s: %w: outside of %s", file.Name, ErrInvalidFile, prefix)
+		}
+		if !file.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s: %w: not a regular file", file.Name, ErrInvalidFile)
+		}
+		if err := CheckFilePath(name); err != nil {
+			return nil, err
+		}
+		if file.UncompressedSize64 > MaxFileSize {
+			return nil, fmt.Errorf("%s: %w: larger than %d bytes", file.Name, ErrInvalidFile, MaxFileSize)
+		}
+		// entries colliding on case-insensitive file systems would
+		// silently overwrite each other
+		if other, exists := folded[strings.ToLower(name)]; exists {
+			return nil, fmt.Errorf("%s: %w: conflicts with %s", file.Name, ErrInvalidFile, other)
+		}
+		folded[strings.ToLower(name)] = name
+		files[name] = file
+	}
+	return files, nil
+}
+
+func extractFile(file *zip.File, targetPath string) error {
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+	in, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	// the header size is checked, but the content may lie about it
+	written, err := io.Copy(out, io.LimitReader(in, MaxFileSize+1))
+	if err == nil && written > MaxFileSize {
+		err = fmt.Errorf("%s: %w: larger than %d bytes", file.Name, ErrInvalidFile, MaxFileSize)
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxFileSize {
+		return nil, fmt.Errorf("%s: %w: larger than %d bytes", name, ErrInvalidFile, MaxFileSize)
+	}
+	return data, nil
+}
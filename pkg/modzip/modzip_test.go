@@ -0,0 +1,252 @@
+package modzip
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
+)
+
+const testModuleIdentifier = "github.com/org/lib@v1.0.0"
+
+func testModuleFS() fstest.MapFS {
+	return fstest.MapFS{
+		"custodian.json":         {Data: []byte(`{"module": "github.com/org/lib"}`)},
+		"main.libsonnet":         {Data: []byte(`{ a: 1 }`)},
+		"lib/util.libsonnet":     {Data: []byte(`{ b: 2 }`)},
+		".git/HEAD":              {Data: []byte("ref: refs/heads/main\n")},
+		"lib/.git/config":        {Data: []byte("")},
+		"lib/nested/x.jsonnet":   {Data: []byte(`1`), Mode: 0755},
+		"lib/nested/y.jsonnet":   {Data: []byte(`2`)},
+		"lib/nested/z.libsonnet": {Data: []byte(`3`)},
+	}
+}
+
+func createZip(t *testing.T, moduleIdentifier string, fsys fstest.MapFS) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Create(&buf, moduleIdentifier, fsys); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCreate(t *testing.T) {
+	data := createZip(t, testModuleIdentifier, testModuleFS())
+
+	// same content in another order and with other modification times
+	other := testModuleFS()
+	other["main.libsonnet"].ModTime = other["main.libsonnet"].ModTime.AddDate(1, 0, 0)
+	if !bytes.Equal(data, createZip(t, testModuleIdentifier, other)) {
+		t.Errorf("Create() is not deterministic")
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() failed: %v", err)
+	}
+	var names []string
+	for _, file := range zipReader.File {
+		names = append(names, file.Name)
+		if !file.Modified.Equal(fixedModTime) || file.Mode() != 0644 {
+			t.Errorf("entry %s has time %v and mode %v", file.Name, file.Modified, file.Mode())
+		}
+	}
+	want := []string{
+		testModuleIdentifier + "/custodian.json",
+		testModuleIdentifier + "/lib/nested/x.jsonnet",
+		testModuleIdentifier + "/lib/nested/y.jsonnet",
+		testModuleIdentifier + "/lib/nested/z.libsonnet",
+		testModuleIdentifier + "/lib/util.libsonnet",
+		testModuleIdentifier + "/main.libsonnet",
+	}
+	if len(names) != len(want) {
+		t.Fatalf("archive entries = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("archive entry %d = %s, want %s", i, names[i], want[i])
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	fsys := testModuleFS()
+	data := createZip(t, testModuleIdentifier, fsys)
+
+	fsHash, err := HashFS(testModuleIdentifier, fsys)
+	if err != nil {
+		t.Fatalf("HashFS() failed: %v", err)
+	}
+	zipHash, err := HashZip(bytes.NewReader(data), int64(len(data)), testModuleIdentifier)
+	if err != nil {
+		t.Fatalf("HashZip() failed: %v", err)
+	}
+	if fsHash != zipHash {
+		t.Errorf("HashFS() = %s, HashZip() = %s", fsHash, zipHash)
+	}
+
+	targetDir := filepath.Join(t.TempDir(), testModuleIdentifier)
+	if err := Extract(bytes.NewReader(data), testModuleIdentifier, targetDir); err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+	dirHash, err := HashFS(testModuleIdentifier, os.DirFS(targetDir))
+	if err != nil {
+		t.Fatalf("HashFS() of extracted module failed: %v", err)
+	}
+	if dirHash != fsHash {
+		t.Errorf("HashFS() of extracted module = %s, want %s", dirHash, fsHash)
+	}
+
+	fsys["main.libsonnet"] = &fstest.MapFile{Data: []byte(`{ a: 2 }`)}
+	changedHash, err := HashFS(testModuleIdentifier, fsys)
+	if err != nil {
+		t.Fatalf("HashFS() failed: %v", err)
+	}
+	if changedHash == fsHash {
+		t.Errorf("HashFS() did not change with the module content")
+	}
+}
+
+func TestExtract_invalid(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		entries []string
+		mode    os.FileMode
+	}{
+		{
+			name:    "parent directory",
+			entries: []string{testModuleIdentifier + "/../evil.jsonnet"},
+		},
+		{
+			name:    "outside of prefix",
+			entries: []string{"github.com/org/other@v1.0.0/main.jsonnet"},
+		},
+		{
+			name:    "absolute path",
+			entries: []string{"/etc/passwd"},
+		},
+		{
+			name:    "symlink",
+			entries: []string{testModuleIdentifier + "/link"},
+			mode:    os.ModeSymlink | 0777,
+		},
+		{
+			name:    "case collision",
+			entries: []string{testModuleIdentifier + "/main.jsonnet", testModuleIdentifier + "/Main.jsonnet"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			for _, name := range tt.entries {
+				header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+				header.SetMode(0644)
+				if tt.mode != 0 {
+					header.SetMode(tt.mode)
+				}
+				fw, err := zw.CreateHeader(header)
+				if err != nil {
+					t.Fatalf("CreateHeader() failed: %v", err)
+				}
+				fw.Write([]byte("x"))
+			}
+			zw.Close()
+
+			parentDir := t.TempDir()
+			targetDir := filepath.Join(parentDir, "module")
+			err := Extract(bytes.NewReader(buf.Bytes()), testModuleIdentifier, targetDir)
+			if !errors.Is(err, ErrInvalidFile) {
+				t.Fatalf("Extract() error = %v, want %v", err, ErrInvalidFile)
+			}
+			entries, _ := os.ReadDir(parentDir)
+			if len(entries) != 0 {
+				t.Errorf("Extract() left %d entries behind", len(entries))
+			}
+		})
+	}
+}
+
+func TestFiles_symlink(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.jsonnet"), []byte("1"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Symlink("/etc/passwd", filepath.Join(dir, "passwd")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if _, err := Files(os.DirFS(dir)); !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("Files() error = %v, want %v", err, ErrInvalidFile)
+	}
+}
+
+func TestHashFS_symlink(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.jsonnet"), []byte("1"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Symlink("main.jsonnet", filepath.Join(dir, "link.jsonnet")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	hash, err := HashFS(testModuleIdentifier, os.DirFS(dir))
+	if err != nil {
+		t.Fatalf("HashFS() failed: %v", err)
+	}
+	// module caches are read through utils.DirFS
+	again, err := HashFS(testModuleIdentifier, utils.DirFS(dir))
+	if err != nil || again != hash {
+		t.Errorf("HashFS() = %s, %v, want %s", again, err, hash)
+	}
+
+	// retargeting the link changes the hash
+	if err := os.WriteFile(filepath.Join(dir, "other.jsonnet"), []byte("1"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	withOther, err := HashFS(testModuleIdentifier, os.DirFS(dir))
+	if err != nil {
+		t.Fatalf("HashFS() failed: %v", err)
+	}
+	os.Remove(filepath.Join(dir, "link.jsonnet"))
+	if err := os.Symlink("other.jsonnet", filepath.Join(dir, "link.jsonnet")); err != nil {
+		t.Fatal(err)
+	}
+	retargeted, err := HashFS(testModuleIdentifier, os.DirFS(dir))
+	if err != nil {
+		t.Fatalf("HashFS() failed: %v", err)
+	}
+	if retargeted == withOther {
+		t.Errorf("HashFS() did not change with the link target")
+	}
+}
+
+func TestOpenFS(t *testing.T) {
+	fsys := testModuleFS()
+	data := createZip(t, testModuleIdentifier, fsys)
+
+	zipFS, err := OpenFS(bytes.NewReader(data), int64(len(data)), testModuleIdentifier)
+	if err != nil {
+		t.Fatalf("OpenFS() failed: %v", err)
+	}
+	content, err := fs.ReadFile(zipFS, "lib/nested/y.jsonnet")
+	if err != nil || string(content) != "2" {
+		t.Errorf("ReadFile() = %q, %v, want %q", content, err, "2")
+	}
+	fsHash, err := HashFS(testModuleIdentifier, fsys)
+	if err != nil {
+		t.Fatalf("HashFS() failed: %v", err)
+	}
+	if zipHash, err := HashFS(testModuleIdentifier, zipFS); err != nil || zipHash != fsHash {
+		t.Errorf("HashFS() of archive = %s, %v, want %s", zipHash, err, fsHash)
+	}
+
+	if _, err := OpenFS(bytes.NewReader(data), int64(len(data)), "github.com/org/other@v1.0.0"); !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("OpenFS() of another module error = %v, want %v", err, ErrInvalidFile)
+	}
+}
@@ -0,0 +1,136 @@
+// Package oci stores modules as OCI artifacts: an image manifest with a
+// single layer holding the module archive in the modzip format.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	Scheme = "oci://"
+
+	ModuleConfigMediaType types.MediaType = "application/vnd.custodian.module.config.v1+json"
+	ModuleLayerMediaType  types.MediaType = "application/vnd.custodian.module.layer.v1.zip"
+	// ModuleAnnotation holds the module identifier the archive entries are
+	// prefixed with, as the digest of the artifact is not known when the
+	// archive is created.
+	ModuleAnnotation = "io.github.git-justanotherone.custodian.module"
+)
+
+// ParseReference parses an oci://registry/repo:tag or
+// oci://registry/repo@sha256:<digest> identifier.
+func ParseReference(identifier string) (name.Reference, error) {
+	ref, found := strings.CutPrefix(identifier, Scheme)
+	if !found {
+		return nil, fmt.Errorf("%s: OCI references must start with %s", identifier, Scheme)
+	}
+	return name.ParseReference(ref)
+}
+
+// Digest returns the manifest digest ref points to.
+func Digest(ctx context.Context, ref name.Reference) (string, error) {
+	desc, err := remote.Head(ref, remoteOptions(ctx)...)
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest.String(), nil
+}
+
+// Push uploads the module in dir to ref, with moduleIdentifier prefixing the
+// archive entries, and returns the manifest digest.
+func Push(ctx context.Context, ref name.Reference, moduleIdentifier string, dir string) (string, error) {
+	var buf bytes.Buffer
+	if err := modzip.CreateFromDir(&buf, moduleIdentifier, dir); err != nil {
+		return "", err
+	}
+
+	img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, ModuleConfigMediaType)
+	img, err := mutate.Append(img, mutate.Addendum{
+		Layer:       static.NewLayer(buf.Bytes(), ModuleLayerMediaType),
+		MediaType:   ModuleLayerMediaType,
+		Annotations: map[string]string{ModuleAnnotation: moduleIdentifier},
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := remote.Write(ref, img, remoteOptions(ctx)...); err != nil {
+		return "", err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return "", err
+	}
+	return digest.String(), nil
+}
+
+// Pull extracts the module archive of the artifact at ref into targetDir and
+// returns the module identifier it was pushed with.
+func Pull(ctx context.Context, ref name.Reference, targetDir string) (string, error) {
+	img, err := remote.Image(ref, remoteOptions(ctx)...)
+	if err != nil {
+		return "", err
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return "", err
+	}
+	layerDesc, err := moduleLayer(manifest)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	moduleIdentifier := layerDesc.Annotations[ModuleAnnotation]
+	if moduleIdentifier == "" {
+		return "", fmt.Errorf("%s: module layer has no %s annotation", ref, ModuleAnnotation)
+	}
+
+	layer, err := img.LayerByDigest(layerDesc.Digest)
+	if err != nil {
+		return "", err
+	}
+	// the blob digest is verified while reading
+	blob, err := layer.Compressed()
+	if err != nil {
+		return "", err
+	}
+	defer blob.Close()
+	if err := modzip.Extract(blob, moduleIdentifier, targetDir); err != nil {
+		return "", fmt.Errorf("invalid module archive in %s: %w", ref, err)
+	}
+	return moduleIdentifier, nil
+}
+
+func moduleLayer(manifest *v1.Manifest) (v1.Descriptor, error) {
+	if manifest.Config.MediaType != ModuleConfigMediaType {
+		return v1.Descriptor{}, fmt.Errorf("not a custodian module: config media type %s", manifest.Config.MediaType)
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == ModuleLayerMediaType {
+			return layer, nil
+		}
+	}
+	return v1.Descriptor{}, fmt.Errorf("no layer of media type %s", ModuleLayerMediaType)
+}
+
+// remoteOptions authenticates with the docker credentials, as docker login
+// and most registry tools store them.
+func remoteOptions(ctx context.Context) []remote.Option {
+	return []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+	}
+}
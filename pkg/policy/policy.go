@@ -0,0 +1,166 @@
+// Package policy restricts the modules a build may use to the remotes and
+// versions allowed by a policy file.
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	goModule "golang.org/x/mod/module"
+)
+
+const (
+	ENV_POLICY     = "CUSTODIAN_POLICY"
+	PolicyFileName = "custodian.policy.json"
+)
+
+var ErrPolicyViolation = errors.New("forbidden by policy")
+
+// Policy is the content of a policy file. A module is allowed when it
+// matches no Deny rule and, if there are Allow rules, at least one of them.
+type Policy struct {
+	Allow []Rule `json:"allow,omitempty"`
+	Deny  []Rule `json:"deny,omitempty"`
+}
+
+// Rule matches the modules whose remote matches Remote and whose version
+// matches Version, globs as accepted by path.Match such as github.com/org/*
+// and v1.*. Empty globs match anything. Pseudo restricts the rule to
+// pseudo-versions.
+type Rule struct {
+	Remote  string `json:"remote,omitempty"`
+	Version string `json:"version,omitempty"`
+	Pseudo  bool   `json:"pseudo,omitempty"`
+	// Reason is reported with the modules denied by the rule.
+	Reason string `json:"reason,omitempty"`
+}
+
+// versionIndependent reports whether the rule matches every version.
+func (r Rule) versionIndependent() bool {
+	return r.Version == "" && !r.Pseudo
+}
+
+func (r Rule) matchesRemote(remote string) bool {
+	if r.Remote == "" {
+		return true
+	}
+	matched, _ := path.Match(r.Remote, remote)
+	return matched
+}
+
+func (r Rule) matches(remote, version string) bool {
+	if !r.matchesRemote(remote) {
+		return false
+	}
+	if r.Pseudo && !goModule.IsPseudoVersion(version) {
+		return false
+	}
+	if r.Version == "" {
+		return true
+	}
+	matched, _ := path.Match(r.Version, version)
+	return matched
+}
+
+func (r Rule) String() string {
+	var fields []string
+	if r.Remote != "" {
+		fields = append(fields, fmt.Sprintf("remote=%q", r.Remote))
+	}
+	if r.Version != "" {
+		fields = append(fields, fmt.Sprintf("version=%q", r.Version))
+	}
+	if r.Pseudo {
+		fields = append(fields, "pseudo=true")
+	}
+	rule := "rule {" + strings.Join(fields, " ") + "}"
+	if r.Reason != "" {
+		rule += " (" + r.Reason + ")"
+	}
+	return rule
+}
+
+// CheckRemote checks the rules matching every version of remote, so that
+// modules of forbidden remotes are refused before being fetched.
+func (p *Policy) CheckRemote(remote string) error {
+	if p == nil {
+		return nil
+	}
+	for _, rule := range p.Deny {
+		if rule.versionIndependent() && rule.matchesRemote(remote) {
+			return fmt.Errorf("%s: %w: denied by %s", remote, ErrPolicyViolation, rule)
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, rule := range p.Allow {
+		if rule.matchesRemote(remote) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %w: not allowed by any rule", remote, ErrPolicyViolation)
+}
+
+// Check checks the version of remote against every rule. Unversioned
+// modules, such as tarballs, have an empty version.
+func (p *Policy) Check(remote, version string) error {
+	if p == nil {
+		return nil
+	}
+	module := remote
+	if version != "" {
+		module += "@" + version
+	}
+	for _, rule := range p.Deny {
+		if rule.matches(remote, version) {
+			return fmt.Errorf("%s: %w: denied by %s", module, ErrPolicyViolation, rule)
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, rule := range p.Allow {
+		if rule.matches(remote, version) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %w: not allowed by any rule", module, ErrPolicyViolation)
+}
+
+// Load reads the policy file named by CUSTODIAN_POLICY, or custodian.policy.json
+// in dir, the directory of the root module. It returns a nil policy, allowing
+// every module, if there is none.
+func Load(dir string) (*Policy, error) {
+	policyPath := os.Getenv(ENV_POLICY)
+	if policyPath == "" {
+		policyPath = filepath.Join(dir, PolicyFileName)
+		if _, err := os.Stat(policyPath); errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+	}
+	data, err := os.ReadFile(policyPath)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", policyPath, err)
+	}
+	for _, rule := range slices.Concat(p.Allow, p.Deny) {
+		if _, err := path.Match(rule.Remote, ""); err != nil {
+			return nil, fmt.Errorf("invalid policy file %s: remote %q: %w", policyPath, rule.Remote, err)
+		}
+		if _, err := path.Match(rule.Version, ""); err != nil {
+			return nil, fmt.Errorf("invalid policy file %s: version %q: %w", policyPath, rule.Version, err)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,136 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	p := &Policy{
+		Allow: []Rule{
+			{Remote: "github.com/org/*"},
+			{Remote: "gitlab.example.com/*/*", Version: "v1.*"},
+		},
+		Deny: []Rule{
+			{Pseudo: true},
+			{Remote: "github.com/org/legacy"},
+		},
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		remote  string
+		version string
+		wantErr bool
+	}{
+		{
+			name:    "allowed remote",
+			remote:  "github.com/org/lib",
+			version: "v2.0.0",
+		},
+		{
+			name:    "pseudo-version",
+			remote:  "github.com/org/lib",
+			version: "v0.0.0-20250101100000-0123456789ab",
+			wantErr: true,
+		},
+		{
+			name:    "pre-release pseudo-version",
+			remote:  "github.com/org/lib",
+			version: "v1.2.4-0.20250101100000-0123456789ab",
+			wantErr: true,
+		},
+		{
+			name:    "denied remote",
+			remote:  "github.com/org/legacy",
+			version: "v1.0.0",
+			wantErr: true,
+		},
+		{
+			name:    "allowed version",
+			remote:  "gitlab.example.com/team/lib",
+			version: "v1.4.0",
+		},
+		{
+			name:    "version not allowed",
+			remote:  "gitlab.example.com/team/lib",
+			version: "v2.0.0",
+			wantErr: true,
+		},
+		{
+			name:    "remote not allowed",
+			remote:  "github.com/other/lib",
+			version: "v1.0.0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotErr := p.Check(tt.remote, tt.version)
+			if gotErr != nil {
+				if !tt.wantErr || !errors.Is(gotErr, ErrPolicyViolation) {
+					t.Errorf("Check() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("Check() succeeded unexpectedly")
+			}
+		})
+	}
+}
+
+func TestPolicy_CheckRemote(t *testing.T) {
+	p := &Policy{
+		Allow: []Rule{{Remote: "gitlab.example.com/*/*", Version: "v1.*"}},
+		Deny:  []Rule{{Pseudo: true}},
+	}
+	if err := p.CheckRemote("gitlab.example.com/team/lib"); err != nil {
+		t.Errorf("CheckRemote() failed: %v", err)
+	}
+	if err := p.CheckRemote("github.com/org/lib"); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("CheckRemote() = %v, want %v", err, ErrPolicyViolation)
+	}
+	if err := (*Policy)(nil).CheckRemote("github.com/org/lib"); err != nil {
+		t.Errorf("CheckRemote() without policy failed: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	// the current directory is not the root module
+	t.Chdir(t.TempDir())
+
+	got, err := Load(dir)
+	if err != nil || got != nil {
+		t.Fatalf("Load() without policy file = %v, %v, want nil", got, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, PolicyFileName), []byte(`{"deny": [{"pseudo": true}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = Load(dir)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(got.Deny) != 1 || !got.Deny[0].Pseudo {
+		t.Errorf("Load() = %+v, want a pseudo-version deny rule", got)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"allow": [{"remote": "github.com/["}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(ENV_POLICY, invalid)
+	if _, err := Load(dir); err == nil {
+		t.Error("Load() of an invalid glob succeeded unexpectedly")
+	}
+
+	t.Setenv(ENV_POLICY, filepath.Join(dir, "missing.json"))
+	if _, err := Load(dir); err == nil {
+		t.Error("Load() of a missing policy file succeeded unexpectedly")
+	}
+}
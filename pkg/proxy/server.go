@@ -0,0 +1,140 @@
+// Package proxy implements a server for the module proxy protocol spoken by
+// the proxy resolver, serving modules from a custodian.Resolver.
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/resolvers"
+
+	goModule "golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+type server struct {
+	resolver custodian.Resolver
+}
+
+// NewHandler returns an http.Handler serving the modules of resolver with the
+// module proxy protocol. The version list is only served if the resolver
+// implements custodian.VersionLister.
+func NewHandler(resolver custodian.Resolver) http.Handler {
+	return &server{resolver: resolver}
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	escapedRemote, file, found := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/@v/")
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	remoteIdentifier, err := goModule.UnescapePath(escapedRemote)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if file == "list" {
+		s.serveList(w, r, remoteIdentifier)
+		return
+	}
+
+	dot := strings.LastIndex(file, ".")
+	if dot < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	extension := file[dot:]
+	switch extension {
+	case ".info", ".mod", ".zip":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	version, err := goModule.UnescapeVersion(file[:dot])
+	if err != nil || version == "" {
+		http.Error(w, "invalid version", http.StatusBadRequest)
+		return
+	}
+
+	module, err := s.resolver.Resolve(r.Context(), remoteIdentifier+resolvers.VersionSeparator+version)
+	if err != nil {
+		log.Printf("Failed to resolve %s@%s: %v", remoteIdentifier, version, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	_, resolvedVersion, _ := strings.Cut(module.Identifier(), resolvers.VersionSeparator)
+
+	switch extension {
+	case ".info":
+		info := resolvers.ProxyVersionInfo{Version: resolvedVersion}
+		if t, err := goModule.PseudoVersionTime(resolvedVersion); err == nil {
+			info.Time = t
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	case ".mod":
+		if resolvedVersion != version {
+			// only canonical versions have stable content
+			http.NotFound(w, r)
+			return
+		}
+		data, err := fs.ReadFile(module.FileSystem(), modules.ModuleFileName)
+		if errors.Is(err, fs.ErrNotExist) {
+			data, err = modules.SerializeModuleFile(&modules.ModuleFile{Module: remoteIdentifier, Require: map[string]string{}})
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	case ".zip":
+		if resolvedVersion != version {
+			http.NotFound(w, r)
+			return
+		}
+		var buf bytes.Buffer
+		if err := modzip.Create(&buf, module.Identifier(), module.FileSystem()); err != nil {
+			log.Printf("Failed to write zip of %s: %v", module.Identifier(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(buf.Bytes())
+	}
+}
+
+func (s *server) serveList(w http.ResponseWriter, r *http.Request, remoteIdentifier string) {
+	lister, ok := s.resolver.(custodian.VersionLister)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	versions, err := lister.ListVersions(r.Context(), remoteIdentifier)
+	if err != nil {
+		log.Printf("Failed to list versions of %s: %v", remoteIdentifier, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	semver.Sort(versions)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, version := range versions {
+		io.WriteString(w, version+"\n")
+	}
+}
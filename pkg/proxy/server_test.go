@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/resolvers"
+)
+
+// testResolver knows github.com/org/lib at v1.0.0, also reachable through
+// the commit abcdef123456.
+type testResolver struct{}
+
+func (r *testResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	switch moduleIdentifier {
+	case "github.com/org/lib@v1.0.0", "github.com/org/lib@abcdef123456":
+		return modules.NewModuleFromFS("github.com/org/lib@v1.0.0", fstest.MapFS{
+			"custodian.json":      {Data: []byte(`{"module": "github.com/org/lib", "require": {"dep": "github.com/org/dep@v0.1.0"}}`)},
+			"main.libsonnet":      {Data: []byte("{ a: 1 }")},
+			"sub/other.libsonnet": {Data: []byte("{ b: 2 }")},
+		})
+	}
+	return nil, fmt.Errorf("%s: %w", moduleIdentifier, resolvers.ErrModuleNotFound)
+}
+
+func (r *testResolver) ListVersions(ctx context.Context, remoteIdentifier string) ([]string, error) {
+	if remoteIdentifier != "github.com/org/lib" {
+		return nil, resolvers.ErrModuleNotFound
+	}
+	return []string{"v1.0.0"}, nil
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(NewHandler(&testResolver{}))
+	defer server.Close()
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "list",
+			path:       "/github.com/org/lib/@v/list",
+			wantStatus: http.StatusOK,
+			wantBody:   "v1.0.0\n",
+		},
+		{
+			name:       "info of a commit",
+			path:       "/github.com/org/lib/@v/abcdef123456.info",
+			wantStatus: http.StatusOK,
+			wantBody:   `"Version":"v1.0.0"`,
+		},
+		{
+			name:       "mod",
+			path:       "/github.com/org/lib/@v/v1.0.0.mod",
+			wantStatus: http.StatusOK,
+			wantBody:   `"dep": "github.com/org/dep@v0.1.0"`,
+		},
+		{
+			name:       "zip of a non canonical version",
+			path:       "/github.com/org/lib/@v/abcdef123456.zip",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "unknown module",
+			path:       "/github.com/org/unknown/@v/v1.0.0.info",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "version escaping the cache",
+			path:       "/github.com/org/lib/@v/...info",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "local path",
+			path:       "/./lib/@v/v1.0.0.info",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatalf("GET %s failed: %v", tt.path, err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("GET %s failed: %v", tt.path, err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("GET %s status = %v, want %v", tt.path, resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantBody != "" && !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("GET %s body does not contain %s", tt.path, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandler_proxyResolver(t *testing.T) {
+	server := httptest.NewServer(NewHandler(&testResolver{}))
+	defer server.Close()
+
+	resolver := resolvers.NewProxyResolver(server.URL, t.TempDir())
+	module, err := resolver.Resolve(context.Background(), "github.com/org/lib")
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if module.Identifier() != "github.com/org/lib@v1.0.0" {
+		t.Errorf("Resolve() = %v, want %v", module.Identifier(), "github.com/org/lib@v1.0.0")
+	}
+	data, err := fs.ReadFile(module.FileSystem(), "sub/other.libsonnet")
+	if err != nil || string(data) != "{ b: 2 }" {
+		t.Errorf("Resolve() module file = %q, %v", data, err)
+	}
+	if deps := module.DependencyList(); len(deps) != 1 || deps[0] != "github.com/org/dep@v0.1.0" {
+		t.Errorf("Resolve() dependencies = %v", deps)
+	}
+}
@@ -0,0 +1,105 @@
+package resolvers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	ENV_CONFIG      = ENV_PREFIX + "CONFIG"
+	CONFIG_FILE_DIR = "custodian"
+	CONFIG_FILENAME = "config.json"
+)
+
+// Config is the user configuration of custodian, read from the file named by
+// CUSTODIAN_CONFIG or from custodian/config.json in the user config directory.
+type Config struct {
+	Git GitConfig `json:"git"`
+}
+
+// GitConfig configures how the git resolver reaches remotes.
+type GitConfig struct {
+	// Hosts are matched in order against the host of each remote; the first
+	// match wins. Remotes without a match use the CUSTODIAN_GIT_* variables.
+	Hosts []GitHostConfig `json:"hosts,omitempty"`
+	// Rewrites change where remotes are fetched from without changing their
+	// identifiers, e.g. to use an internal mirror.
+	Rewrites []GitURLRewrite `json:"rewrites,omitempty"`
+	// Trust lists the keys allowed to sign the modules of remotes. Modules of
+	// remotes without a match are not verified.
+	Trust []GitTrustConfig `json:"trust,omitempty"`
+}
+
+// GitTrustConfig lists the keys allowed to sign the tags of the remotes whose
+// identifier matches Remote, a glob as accepted by path.Match such as
+// github.com/grafana/*. Annotated tags must be signed by one of the keys;
+// other versions, including pseudo-versions, by their commit.
+type GitTrustConfig struct {
+	Remote string `json:"remote"`
+	// OpenPGPKeys are ASCII-armored OpenPGP public keys.
+	OpenPGPKeys []string `json:"openpgpKeys,omitempty"`
+	// SSHKeys are SSH public keys in the authorized_keys format.
+	SSHKeys []string `json:"sshKeys,omitempty"`
+}
+
+// GitURLRewrite replaces the InsteadOf prefix of a remote identifier, or of
+// the URL built for it, with Base, like git's url.<base>.insteadOf. When
+// several rules match, the longest InsteadOf wins.
+type GitURLRewrite struct {
+	Base      string `json:"base"`
+	InsteadOf string `json:"insteadOf"`
+}
+
+// GitHostConfig holds the authentication and URL scheme for the remotes
+// whose host matches Host, a glob as accepted by path.Match.
+type GitHostConfig struct {
+	Host     string      `json:"host"`
+	AuthMode GitAuthMode `json:"authMode,omitempty"`
+	User     string      `json:"user,omitempty"`
+	Password string      `json:"password,omitempty"`
+	Token    string      `json:"token,omitempty"`
+	SshKey   string      `json:"sshKey,omitempty"`
+	// Scheme is "https", "http" or "ssh". It defaults to ssh for the ssh
+	// auth modes and to https otherwise.
+	Scheme string `json:"scheme,omitempty"`
+	// Port overrides the default port of the scheme.
+	Port int `json:"port,omitempty"`
+
+	// KnownHosts lists the known_hosts files used to verify SSH host keys,
+	// replacing SSH_KNOWN_HOSTS and ~/.ssh/known_hosts.
+	KnownHosts []string `json:"knownHosts,omitempty"`
+	// HostFingerprints pins the accepted SSH host keys by fingerprint,
+	// e.g. SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU.
+	HostFingerprints []string `json:"hostFingerprints,omitempty"`
+	// InsecureHostKey disables SSH host key verification.
+	InsecureHostKey bool `json:"insecureHostKey,omitempty"`
+}
+
+// LoadConfig reads the user configuration. A missing file is not an error
+// and yields an empty configuration.
+func LoadConfig() (*Config, error) {
+	configPath := os.Getenv(ENV_CONFIG)
+	if configPath == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return &Config{}, nil
+		}
+		configPath = filepath.Join(configDir, CONFIG_FILE_DIR, CONFIG_FILENAME)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
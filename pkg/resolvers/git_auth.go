@@ -0,0 +1,257 @@
+package resolvers
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// gitRemoteAuth is the authentication and URL scheme used for a host.
+type gitRemoteAuth struct {
+	mode   GitAuthMode
+	method transport.AuthMethod
+	scheme string
+	port   int
+}
+
+// gitRemote is the URL and authentication used to fetch a remote.
+type gitRemote struct {
+	url    string
+	method transport.AuthMethod
+}
+
+// gitAuthProvider selects the authentication for each remote from, in order
+// of precedence, the CUSTODIAN_GIT_AUTH_<HOST> variables, the hosts of the
+// user configuration and the global CUSTODIAN_GIT_* variables.
+type gitAuthProvider struct {
+	hosts    []GitHostConfig
+	rewrites []GitURLRewrite
+	fallback GitHostConfig
+
+	mu     sync.Mutex
+	byHost map[string]*gitRemoteAuth
+}
+
+func newGitAuthProvider(config *Config) *gitAuthProvider {
+	return &gitAuthProvider{
+		hosts:    config.Git.Hosts,
+		rewrites: append(getURLRewritesFromEnv(), config.Git.Rewrites...),
+		fallback: getHostConfigFromEnv(),
+		byHost:   make(map[string]*gitRemoteAuth),
+	}
+}
+
+// forRemote returns the URL and authentication for the remote identifier.
+// The URL is built with the scheme configured for the host of the remote,
+// unless a rewrite rule matches the identifier or the built URL. The
+// authentication is the one of the host the URL finally points to.
+func (p *gitAuthProvider) forRemote(remoteIdentifier string) (*gitRemote, error) {
+	remoteURL, rewritten := rewriteURL(p.rewrites, remoteIdentifier)
+	if !rewritten && GitModuleIdentifier(remoteIdentifier).IsLocal() {
+		// local repositories need no authentication
+		repoPath, err := GitModuleIdentifier(remoteIdentifier).LocalPath()
+		if err != nil {
+			return nil, err
+		}
+		return &gitRemote{url: repoPath}, nil
+	}
+	if !rewritten {
+		auth, err := p.forHost(remoteHost(remoteIdentifier))
+		if err != nil {
+			return nil, err
+		}
+		remoteURL = buildRemoteURL(auth.scheme, auth.port, remoteIdentifier)
+		if remoteURL, rewritten = rewriteURL(p.rewrites, remoteURL); !rewritten {
+			return &gitRemote{url: remoteURL, method: auth.method}, nil
+		}
+	}
+
+	host := urlHost(remoteURL)
+	if host == "" {
+		// local repositories need no authentication
+		return &gitRemote{url: remoteURL}, nil
+	}
+	auth, err := p.forHost(host)
+	if err != nil {
+		return nil, err
+	}
+	return &gitRemote{url: remoteURL, method: auth.method}, nil
+}
+
+// forHost returns the authentication for host.
+func (p *gitAuthProvider) forHost(host string) (*gitRemoteAuth, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if auth, exists := p.byHost[host]; exists {
+		return auth, nil
+	}
+
+	hostConfig, err := p.hostConfig(host)
+	if err != nil {
+		return nil, err
+	}
+	method, err := newAuthMethod(hostConfig.AuthMode, host, gitCredentials{
+		User:     hostConfig.User,
+		Password: hostConfig.Password,
+		Token:    hostConfig.Token,
+		SshKey:   hostConfig.SshKey,
+		HostKey: gitHostKeyOptions{
+			KnownHosts:   hostConfig.KnownHosts,
+			Fingerprints: hostConfig.HostFingerprints,
+			Insecure:     hostConfig.InsecureHostKey,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure git auth for %s: %w", host, err)
+	}
+	auth := &gitRemoteAuth{
+		mode:   hostConfig.AuthMode,
+		method: method,
+		scheme: hostConfig.Scheme,
+		port:   hostConfig.Port,
+	}
+	if auth.scheme == "" {
+		auth.scheme = defaultScheme(auth.mode)
+	}
+	p.byHost[host] = auth
+	return auth, nil
+}
+
+// hostConfig returns the configuration for host.
+func (p *gitAuthProvider) hostConfig(host string) (*GitHostConfig, error) {
+	envSuffix := hostEnvSuffix(host)
+	if authMode, exists := os.LookupEnv(ENV_GIT_AUTH + envSuffix); exists {
+		port, err := strconv.Atoi(utils.GetEnv(ENV_GIT_PORT+envSuffix, "0"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", ENV_GIT_PORT+envSuffix, err)
+		}
+		return &GitHostConfig{
+			Host:     host,
+			AuthMode: GitAuthMode(authMode),
+			User:     hostEnvOrGlobal(ENV_GIT_USER, envSuffix),
+			Password: hostEnvOrGlobal(ENV_GIT_PASS, envSuffix),
+			Token:    hostEnvOrGlobal(ENV_GIT_AUTH_TOKEN, envSuffix),
+			SshKey:   hostEnvOrGlobal(ENV_GIT_SSH_KEY, envSuffix),
+			Scheme:   os.Getenv(ENV_GIT_SCHEME + envSuffix),
+			Port:     port,
+
+			KnownHosts:       splitPathList(hostEnvOrGlobal(ENV_GIT_KNOWN_HOSTS, envSuffix)),
+			HostFingerprints: splitList(hostEnvOrGlobal(ENV_GIT_SSH_HOST_FINGERPRINT, envSuffix)),
+			InsecureHostKey:  hostEnvOrGlobal(ENV_GIT_SSH_INSECURE, envSuffix) == "true",
+		}, nil
+	}
+
+	for _, hostConfig := range p.hosts {
+		matched, err := path.Match(hostConfig.Host, host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid git host pattern %q: %w", hostConfig.Host, err)
+		}
+		if matched {
+			return &hostConfig, nil
+		}
+	}
+	return &p.fallback, nil
+}
+
+func defaultScheme(authMode GitAuthMode) string {
+	switch authMode {
+	case GitAuthModeSshKey, GitAuthModeSshAgent:
+		return GitSchemeSsh
+	default:
+		return GitSchemeHttps
+	}
+}
+
+// remoteHost returns the host of a remote identifier such as
+// host_fqdn/owner/repo.
+func remoteHost(remoteIdentifier string) string {
+	host, _, _ := strings.Cut(remoteIdentifier, "/")
+	return host
+}
+
+// urlHost returns the host of a git URL, either a URL with a scheme or the
+// scp-like user@host:path syntax. It returns an empty string for file URLs
+// and local paths.
+func urlHost(remoteURL string) string {
+	if strings.Contains(remoteURL, "://") {
+		parsedURL, err := url.Parse(remoteURL)
+		if err != nil {
+			return ""
+		}
+		return parsedURL.Hostname()
+	}
+	if utils.IsLocalPath(remoteURL) {
+		return ""
+	}
+	hostPath, _, found := strings.Cut(remoteURL, ":")
+	if !found {
+		return ""
+	}
+	_, host, found := strings.Cut(hostPath, "@")
+	if !found {
+		return hostPath
+	}
+	return host
+}
+
+// rewriteURL replaces the longest InsteadOf prefix of remoteURL matched by
+// the rewrite rules with its base, like git's url.<base>.insteadOf.
+func rewriteURL(rewrites []GitURLRewrite, remoteURL string) (string, bool) {
+	var longest *GitURLRewrite
+	for i, rewrite := range rewrites {
+		if rewrite.InsteadOf == "" || !strings.HasPrefix(remoteURL, rewrite.InsteadOf) {
+			continue
+		}
+		if longest == nil || len(rewrite.InsteadOf) > len(longest.InsteadOf) {
+			longest = &rewrites[i]
+		}
+	}
+	if longest == nil {
+		return remoteURL, false
+	}
+	return longest.Base + strings.TrimPrefix(remoteURL, longest.InsteadOf), true
+}
+
+// getURLRewritesFromEnv parses CUSTODIAN_GIT_INSTEADOF, a comma separated
+// list of <base>=<insteadOf> rules.
+func getURLRewritesFromEnv() []GitURLRewrite {
+	var rewrites []GitURLRewrite
+	for _, rule := range splitList(utils.GetEnvOrEmpty(ENV_GIT_INSTEADOF)) {
+		separator := strings.LastIndex(rule, "=")
+		if separator < 0 {
+			continue
+		}
+		rewrites = append(rewrites, GitURLRewrite{
+			Base:      rule[:separator],
+			InsteadOf: rule[separator+1:],
+		})
+	}
+	return rewrites
+}
+
+// hostEnvSuffix converts a host to the suffix of its environment variables,
+// e.g. gitlab.example.com becomes _GITLAB_EXAMPLE_COM.
+func hostEnvSuffix(host string) string {
+	return "_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, host)
+}
+
+func hostEnvOrGlobal(envVar, envSuffix string) string {
+	return utils.GetEnv(envVar+envSuffix, utils.GetEnvOrEmpty(envVar))
+}
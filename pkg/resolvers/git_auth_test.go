@@ -0,0 +1,154 @@
+package resolvers
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+func Test_gitAuthProvider_forRemote(t *testing.T) {
+	t.Setenv(ENV_GIT_AUTH_MODE, string(GitAuthModeAuthToken))
+	t.Setenv(ENV_GIT_AUTH_TOKEN, "global-token")
+	t.Setenv(ENV_GIT_AUTH+"_GIT_INTERNAL_IO", string(GitAuthModeBasicAuth))
+	t.Setenv(ENV_GIT_USER+"_GIT_INTERNAL_IO", "internal-user")
+	t.Setenv(ENV_GIT_PASS, "global-pass")
+
+	provider := newGitAuthProvider(&Config{
+		Git: GitConfig{
+			Hosts: []GitHostConfig{
+				{Host: "*.example.com", AuthMode: GitAuthModeBasicAuth, User: "example", Password: "secret"},
+				{Host: "github.com", AuthMode: GitAuthModeNone},
+			},
+		},
+	})
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		remoteIdentifier string
+		wantURL          string
+		wantMethod       any
+	}{
+		{
+			name:             "host matching a config glob",
+			remoteIdentifier: "gitlab.example.com/org/lib",
+			wantURL:          "https://gitlab.example.com/org/lib",
+			wantMethod:       &http.BasicAuth{Username: "example", Password: "secret"},
+		},
+		{
+			name:             "host configured without auth",
+			remoteIdentifier: "github.com/org/lib",
+			wantURL:          "https://github.com/org/lib",
+			wantMethod:       nil,
+		},
+		{
+			name:             "host configured by environment",
+			remoteIdentifier: "git.internal.io/org/lib",
+			wantURL:          "https://git.internal.io/org/lib",
+			wantMethod:       &http.BasicAuth{Username: "internal-user", Password: "global-pass"},
+		},
+		{
+			name:             "host using the global environment",
+			remoteIdentifier: "bitbucket.org/org/lib",
+			wantURL:          "https://bitbucket.org/org/lib",
+			wantMethod:       &http.TokenAuth{Token: "global-token"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := provider.forRemote(tt.remoteIdentifier)
+			if gotErr != nil {
+				t.Fatalf("forRemote() failed: %v", gotErr)
+			}
+			if got.url != tt.wantURL {
+				t.Errorf("forRemote() url = %v, want %v", got.url, tt.wantURL)
+			}
+			switch want := tt.wantMethod.(type) {
+			case nil:
+				if got.method != nil {
+					t.Errorf("forRemote() method = %v, want nil", got.method)
+				}
+			case *http.BasicAuth:
+				if gotMethod, ok := got.method.(*http.BasicAuth); !ok || *gotMethod != *want {
+					t.Errorf("forRemote() method = %v, want %v", got.method, want)
+				}
+			case *http.TokenAuth:
+				if gotMethod, ok := got.method.(*http.TokenAuth); !ok || *gotMethod != *want {
+					t.Errorf("forRemote() method = %v, want %v", got.method, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_gitAuthProvider_forRemote_urls(t *testing.T) {
+	t.Setenv(ENV_GIT_AUTH_MODE, "")
+	t.Setenv(ENV_GIT_INSTEADOF, "https://mirror.internal/github/=github.com/")
+
+	provider := newGitAuthProvider(&Config{
+		Git: GitConfig{
+			Hosts: []GitHostConfig{
+				{Host: "ssh.example.com", Scheme: GitSchemeSsh, Port: 2222},
+				{Host: "plain.example.com", Scheme: GitSchemeHttp},
+				{Host: "mirror.internal", AuthMode: GitAuthModeAuthToken, Token: "mirror-token"},
+			},
+			Rewrites: []GitURLRewrite{
+				{Base: "file:///srv/git/", InsteadOf: "github.com/local-org/"},
+				{Base: "ssh://git@git.example.com:2200/", InsteadOf: "https://gitlab.com/"},
+			},
+		},
+	})
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		remoteIdentifier string
+		wantURL          string
+		wantMethod       *http.TokenAuth
+	}{
+		{
+			name:             "ssh scheme with custom port",
+			remoteIdentifier: "ssh.example.com/org/lib",
+			wantURL:          "ssh://git@ssh.example.com:2222/org/lib",
+		},
+		{
+			name:             "http scheme",
+			remoteIdentifier: "plain.example.com/org/lib",
+			wantURL:          "http://plain.example.com/org/lib",
+		},
+		{
+			name:             "identifier rewritten to a mirror using the mirror auth",
+			remoteIdentifier: "github.com/org/lib",
+			wantURL:          "https://mirror.internal/github/org/lib",
+			wantMethod:       &http.TokenAuth{Token: "mirror-token"},
+		},
+		{
+			name:             "longest rewrite wins",
+			remoteIdentifier: "github.com/local-org/lib",
+			wantURL:          "file:///srv/git/lib",
+		},
+		{
+			name:             "built URL rewritten",
+			remoteIdentifier: "gitlab.com/org/lib",
+			wantURL:          "ssh://git@git.example.com:2200/org/lib",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := provider.forRemote(tt.remoteIdentifier)
+			if gotErr != nil {
+				t.Fatalf("forRemote() failed: %v", gotErr)
+			}
+			if got.url != tt.wantURL {
+				t.Errorf("forRemote() url = %v, want %v", got.url, tt.wantURL)
+			}
+			if tt.wantMethod == nil {
+				if got.method != nil {
+					t.Errorf("forRemote() method = %v, want nil", got.method)
+				}
+			} else if gotMethod, ok := got.method.(*http.TokenAuth); !ok || *gotMethod != *tt.wantMethod {
+				t.Errorf("forRemote() method = %v, want %v", got.method, tt.wantMethod)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package resolvers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const (
+	ENV_GIT_CREDENTIAL_HELPER = ENV_PREFIX + "GIT_CREDENTIAL_HELPER"
+	ENV_NETRC                 = "NETRC"
+	DEFAULT_CREDENTIAL_HELPER = "git credential fill"
+)
+
+// credentialHelperAuth asks the git credential helpers for the credentials of
+// host, falling back to the .netrc file. It returns a nil auth method if
+// neither has credentials, so the remote is accessed anonymously.
+func credentialHelperAuth(host string) (transport.AuthMethod, error) {
+	username, password, err := credentialFill(host)
+	if err != nil {
+		log.Printf("Credential helper failed for %s, trying .netrc: %v", host, err)
+	}
+	if username == "" && password == "" {
+		username, password, err = netrcLookup(host)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if username == "" && password == "" {
+		return nil, nil
+	}
+	return &http.BasicAuth{
+		Username: username,
+		Password: password,
+	}, nil
+}
+
+// credentialFill runs the credential helper command, "git credential fill"
+// unless CUSTODIAN_GIT_CREDENTIAL_HELPER overrides it, following the git
+// credential protocol: the request is written to stdin as key=value lines
+// and the username and password are read from stdout in the same format.
+func credentialFill(host string) (username, password string, err error) {
+	helper := strings.Fields(os.Getenv(ENV_GIT_CREDENTIAL_HELPER))
+	if len(helper) == 0 {
+		helper = strings.Fields(DEFAULT_CREDENTIAL_HELPER)
+	}
+
+	cmd := exec.Command(helper[0], helper[1:]...)
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=https\nhost=%s\n\n", host))
+	cmd.Stderr = os.Stderr
+	// never block waiting for a password on the terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+	return username, password, scanner.Err()
+}
+
+// netrcLookup returns the login and password for host from the file named by
+// NETRC or from .netrc (_netrc on Windows) in the home directory. A missing
+// file yields empty credentials.
+func netrcLookup(host string) (login, password string, err error) {
+	netrcPath := os.Getenv(ENV_NETRC)
+	if netrcPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", nil
+		}
+		netrcName := ".netrc"
+		if runtime.GOOS == "windows" {
+			netrcName = "_netrc"
+		}
+		netrcPath = filepath.Join(home, netrcName)
+	}
+
+	data, err := os.ReadFile(netrcPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+	login, password = parseNetrc(string(data), host)
+	return login, password, nil
+}
+
+// parseNetrc returns the credentials of the machine entry matching host, or
+// of the default entry if there is no match.
+func parseNetrc(data string, host string) (login, password string) {
+	type entry struct {
+		login, password string
+	}
+	var current *entry
+	var machine, defaults *entry
+
+	tokens := strings.Fields(data)
+	for i := 0; i < len(tokens); i++ {
+		next := func() string {
+			if i+1 < len(tokens) {
+				i++
+				return tokens[i]
+			}
+			return ""
+		}
+		switch tokens[i] {
+		case "machine":
+			current = &entry{}
+			if next() == host && machine == nil {
+				machine = current
+			}
+		case "default":
+			current = &entry{}
+			if defaults == nil {
+				defaults = current
+			}
+		case "login":
+			if value := next(); current != nil {
+				current.login = value
+			}
+		case "password":
+			if value := next(); current != nil {
+				current.password = value
+			}
+		case "account":
+			next()
+		}
+	}
+
+	if machine != nil {
+		return machine.login, machine.password
+	}
+	if defaults != nil {
+		return defaults.login, defaults.password
+	}
+	return "", ""
+}
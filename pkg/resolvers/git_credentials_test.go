@@ -0,0 +1,89 @@
+package resolvers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const testCredentialHelper = `#!/bin/sh
+while read line; do
+	[ -z "$line" ] && break
+	case "$line" in
+		host=helper.example.com) found=1 ;;
+	esac
+done
+if [ -n "$found" ]; then
+	echo "protocol=https"
+	echo "host=helper.example.com"
+	echo "username=helper-user"
+	echo "password=helper-pass"
+fi
+`
+
+const testNetrc = `machine netrc.example.com
+	login netrc-user
+	password netrc-pass
+machine other.example.com login other-user password other-pass
+`
+
+func Test_credentialHelperAuth(t *testing.T) {
+	dir := t.TempDir()
+	helperPath := filepath.Join(dir, "helper.sh")
+	if err := os.WriteFile(helperPath, []byte(testCredentialHelper), 0755); err != nil {
+		t.Fatalf("Failed to write credential helper: %v", err)
+	}
+	netrcPath := filepath.Join(dir, "netrc")
+	if err := os.WriteFile(netrcPath, []byte(testNetrc), 0600); err != nil {
+		t.Fatalf("Failed to write netrc: %v", err)
+	}
+	t.Setenv(ENV_GIT_CREDENTIAL_HELPER, helperPath)
+	t.Setenv(ENV_NETRC, netrcPath)
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		host string
+		want *http.BasicAuth
+	}{
+		{
+			name: "credentials from the helper",
+			host: "helper.example.com",
+			want: &http.BasicAuth{Username: "helper-user", Password: "helper-pass"},
+		},
+		{
+			name: "credentials from netrc",
+			host: "netrc.example.com",
+			want: &http.BasicAuth{Username: "netrc-user", Password: "netrc-pass"},
+		},
+		{
+			name: "credentials from a single line netrc entry",
+			host: "other.example.com",
+			want: &http.BasicAuth{Username: "other-user", Password: "other-pass"},
+		},
+		{
+			name: "no credentials",
+			host: "unknown.example.com",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := credentialHelperAuth(tt.host)
+			if gotErr != nil {
+				t.Fatalf("credentialHelperAuth() failed: %v", gotErr)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("credentialHelperAuth() = %v, want nil", got)
+				}
+				return
+			}
+			if gotAuth, ok := got.(*http.BasicAuth); !ok || *gotAuth != *tt.want {
+				t.Errorf("credentialHelperAuth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
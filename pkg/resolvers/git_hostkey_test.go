@@ -0,0 +1,101 @@
+package resolvers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate host key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to convert host key: %v", err)
+	}
+	return key
+}
+
+func Test_hostKeyCallback(t *testing.T) {
+	hostKey := newTestHostKey(t)
+	otherKey := newTestHostKey(t)
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+
+	knownHostsPath := filepath.Join(t.TempDir(), "known_hosts")
+	knownHostsLine := knownhosts.Line([]string{"git.example.com"}, hostKey) + "\n"
+	if err := os.WriteFile(knownHostsPath, []byte(knownHostsLine), 0600); err != nil {
+		t.Fatalf("Failed to write known hosts: %v", err)
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		options gitHostKeyOptions
+		key     ssh.PublicKey
+		wantErr bool
+	}{
+		{
+			name:    "pinned SHA256 fingerprint",
+			options: gitHostKeyOptions{Fingerprints: []string{ssh.FingerprintSHA256(hostKey)}},
+			key:     hostKey,
+			wantErr: false,
+		},
+		{
+			name:    "pinned MD5 fingerprint",
+			options: gitHostKeyOptions{Fingerprints: []string{ssh.FingerprintLegacyMD5(hostKey)}},
+			key:     hostKey,
+			wantErr: false,
+		},
+		{
+			name:    "key not matching the pinned fingerprint",
+			options: gitHostKeyOptions{Fingerprints: []string{ssh.FingerprintSHA256(hostKey)}},
+			key:     otherKey,
+			wantErr: true,
+		},
+		{
+			name:    "key in known hosts file",
+			options: gitHostKeyOptions{KnownHosts: []string{knownHostsPath}},
+			key:     hostKey,
+			wantErr: false,
+		},
+		{
+			name:    "key not in known hosts file",
+			options: gitHostKeyOptions{KnownHosts: []string{knownHostsPath}},
+			key:     otherKey,
+			wantErr: true,
+		},
+		{
+			name:    "insecure mode",
+			options: gitHostKeyOptions{Insecure: true, Fingerprints: []string{ssh.FingerprintSHA256(hostKey)}},
+			key:     otherKey,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callback, err := hostKeyCallback("git.example.com", tt.options)
+			if err != nil {
+				t.Fatalf("hostKeyCallback() failed: %v", err)
+			}
+			gotErr := callback("git.example.com:22", remote, tt.key)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("host key rejected: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("host key accepted unexpectedly")
+			}
+		})
+	}
+}
@@ -1,18 +1,97 @@
 package resolvers
 
-import "strings"
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
+)
 
 // ModuleIdentifier represents a module identifier string
-// e.g. host_fqdn/owner/repo[/branch]@version
+// e.g. host_fqdn/owner/repo[/branch][//subpath]@version
 //     |-----Git Remote-----|
+//
+// A subpath makes the module a directory of the repository rather than the
+// whole repository.
+//
+// Git repositories on disk are identified by a file URL or a local path, the
+// remote being everything before the last '@'
+// e.g. file:///path/to/repo@version or ../repo@version
 
 const (
 	VersionSeparator = "@"
+	SubpathSeparator = "//"
+	FileScheme       = "file://"
 )
 
 type GitModuleIdentifier string
 
+// IsLocalGitIdentifier reports whether moduleIdentifier is a git repository
+// on disk: a file URL, or a local path with a version. Local paths without a
+// version are plain directories.
+func IsLocalGitIdentifier(moduleIdentifier string) bool {
+	return strings.HasPrefix(moduleIdentifier, FileScheme) || utils.IsVersionedLocalPath(moduleIdentifier)
+}
+
+// IsLocal reports whether the identifier is a git repository on disk.
+func (m GitModuleIdentifier) IsLocal() bool {
+	return strings.HasPrefix(string(m), FileScheme) || utils.IsLocalPath(string(m))
+}
+
+// splitSubpath returns the identifier without its subpath, and the subpath.
+func (m GitModuleIdentifier) splitSubpath() (GitModuleIdentifier, string) {
+	start := 0
+	if strings.HasPrefix(string(m), FileScheme) {
+		start = len(FileScheme)
+	}
+	separator := strings.Index(string(m)[start:], SubpathSeparator)
+	if separator < 0 {
+		return m, ""
+	}
+	separator += start
+	subpath := string(m)[separator+len(SubpathSeparator):]
+	version := ""
+	if i := strings.LastIndex(subpath, VersionSeparator); i >= 0 && !strings.Contains(subpath[i:], "/") {
+		subpath, version = subpath[:i], subpath[i:]
+	}
+	return GitModuleIdentifier(string(m)[:separator] + version), subpath
+}
+
+// Subpath returns the directory of the repository the module is made of, or
+// "" for the whole repository.
+func (m GitModuleIdentifier) Subpath() string {
+	_, subpath := m.splitSubpath()
+	return subpath
+}
+
+// splitLocal splits a local identifier at its last version separator, as
+// local paths may contain the separator themselves.
+func (m GitModuleIdentifier) splitLocal() (remote, version string) {
+	m, _ = m.splitSubpath()
+	separator := strings.LastIndex(string(m), VersionSeparator)
+	if separator < 0 || strings.Contains(string(m)[separator:], "/") {
+		return string(m), ""
+	}
+	return string(m)[:separator], string(m)[separator+1:]
+}
+
+// LocalPath returns the absolute path of the repository of a local
+// identifier.
+func (m GitModuleIdentifier) LocalPath() (string, error) {
+	remote, _ := m.splitLocal()
+	if repoPath, found := strings.CutPrefix(remote, FileScheme); found {
+		return filepath.FromSlash(repoPath), nil
+	}
+	return filepath.Abs(remote)
+}
+
 func (m GitModuleIdentifier) Remote() string {
+	m, _ = m.splitSubpath()
+	if m.IsLocal() {
+		remote, _ := m.splitLocal()
+		return remote
+	}
 	mIdData := strings.SplitN(string(m), VersionSeparator, 2)
 	// remove branch if present
 	remoteData := strings.SplitN(mIdData[0], "/", 4)
@@ -23,6 +102,10 @@ func (m GitModuleIdentifier) Remote() string {
 }
 
 func (m GitModuleIdentifier) Repo() string {
+	m, _ = m.splitSubpath()
+	if m.IsLocal() {
+		return strings.TrimSuffix(path.Base(m.Remote()), ".git")
+	}
 	mIdData := strings.SplitN(string(m), VersionSeparator, 2)
 	remoteData := strings.SplitN(mIdData[0], "/", 4)
 	if len(remoteData) >= 3 {
@@ -32,6 +115,11 @@ func (m GitModuleIdentifier) Repo() string {
 }
 
 func (m GitModuleIdentifier) Version() string {
+	m, _ = m.splitSubpath()
+	if m.IsLocal() {
+		_, version := m.splitLocal()
+		return version
+	}
 	mIdData := strings.SplitN(string(m), VersionSeparator, 2)
 	if len(mIdData) == 2 {
 		return mIdData[1]
@@ -40,8 +128,13 @@ func (m GitModuleIdentifier) Version() string {
 }
 
 func (m GitModuleIdentifier) Branch() string {
-	remote := m.Remote()
-	branchData := strings.SplitN(remote, "/", 4)
+	m, _ = m.splitSubpath()
+	if m.IsLocal() {
+		// local paths have no fixed depth to tell a branch from a directory
+		return ""
+	}
+	mIdData := strings.SplitN(string(m), VersionSeparator, 2)
+	branchData := strings.SplitN(mIdData[0], "/", 4)
 	if len(branchData) == 4 {
 		return branchData[3]
 	}
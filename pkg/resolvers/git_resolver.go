@@ -8,63 +8,170 @@ import (
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/plumbing/transport"
 	goModule "golang.org/x/mod/module"
 	"golang.org/x/mod/semver"
 )
 
+const (
+	localCacheDir   = "file"
+	subpathCacheDir = "subpath"
+	signerCacheDir  = "signer"
+
+	// maxBaseTagWalk bounds the commits searched for the base tag of a
+	// pseudo-version.
+	maxBaseTagWalk = 100000
+)
+
+// gitModule is a module resolved from a git remote, with the branch it
+// tracks and the key it is signed with, if any.
+type gitModule struct {
+	custodian.Module
+	branch string
+	signer string
+}
+
+func (m *gitModule) Branch() string {
+	return m.branch
+}
+
+func (m *gitModule) Signer() string {
+	return m.signer
+}
+
 type gitResolver struct {
-	auth           transport.AuthMethod
-	authMode       GitAuthMode
+	auth           *gitAuthProvider
+	trust          []GitTrustConfig
 	moduleCacheDir string
+
+	// tagCache holds the tags of each remote URL, listed once per run
+	tagCacheMu sync.Mutex
+	tagCache   map[string]map[string]string
 }
 
 func (f *gitResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
-	resolvedIdentifier, err := f.getModule(moduleIdentifier)
+	resolvedIdentifier, err := f.getModule(ctx, moduleIdentifier)
 	if err != nil {
 		return nil, err
 	}
 
 	moduleDir := f.modulePathFromIdentifier(resolvedIdentifier)
 
-	rFs := os.DirFS(moduleDir)
-	return modules.NewModuleFromFS(resolvedIdentifier, rFs)
+	rFs := utils.DirFS(moduleDir)
+	module, err := modules.NewModuleFromFS(resolvedIdentifier, rFs)
+	if err != nil {
+		return nil, err
+	}
+	branch := GitModuleIdentifier(resolvedIdentifier).Branch()
+	signer, _ := os.ReadFile(f.signerPath(moduleDir))
+	if branch != "" || len(signer) > 0 {
+		return &gitModule{Module: module, branch: branch, signer: string(signer)}, nil
+	}
+	return module, nil
+}
+
+// ListVersions returns the semver tags of the remote, sorted.
+func (f *gitResolver) ListVersions(ctx context.Context, remoteIdentifier string) ([]string, error) {
+	remote, err := f.auth.forRemote(remoteIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	tagCommits, err := f.tagCommits(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for tag := range tagCommits {
+		if semver.IsValid(tag) {
+			versions = append(versions, tag)
+		}
+	}
+	semver.Sort(versions)
+	return versions, nil
 }
 
 func (f *gitResolver) modulePathFromIdentifier(moduleIdentifier string) string {
+	mId := GitModuleIdentifier(moduleIdentifier)
+	if base, subpath := mId.splitSubpath(); subpath != "" {
+		// subpaths live apart from the whole repository they are taken from
+		basePath := f.modulePathFromIdentifier(string(base))
+		relPath, err := filepath.Rel(f.moduleCacheDir, basePath)
+		if err != nil {
+			relPath = string(base)
+		}
+		return filepath.Join(f.moduleCacheDir, subpathCacheDir, relPath, filepath.FromSlash(subpath))
+	}
+	if mId.IsLocal() {
+		// relative paths are cached by absolute path, so they never escape
+		// the cache and different repositories never share an entry
+		repoPath, err := mId.LocalPath()
+		if err != nil {
+			repoPath = mId.Remote()
+		}
+		volume := filepath.VolumeName(repoPath)
+		return filepath.Join(f.moduleCacheDir, localCacheDir, strings.TrimSuffix(volume, ":"),
+			repoPath[len(volume):]+VersionSeparator+mId.Version())
+	}
+	if mId.Branch() != "" && mId.Version() != "" {
+		// a pinned commit is the same module whichever branch it is on
+		return path.Join(f.moduleCacheDir, mId.Remote()+VersionSeparator+mId.Version())
+	}
 	return path.Join(f.moduleCacheDir, moduleIdentifier)
 }
 
-func (f *gitResolver) getModule(moduleIdentifier string) (string, error) {
-	// module already exists in module cache
-	if utils.DirExists(f.modulePathFromIdentifier(moduleIdentifier)) {
-		return moduleIdentifier, nil
+// signerPath returns the file recording the signer of the module cached in
+// moduleDir, kept out of the module cache so it is not part of any module.
+func (f *gitResolver) signerPath(moduleDir string) string {
+	relPath, err := filepath.Rel(f.moduleCacheDir, moduleDir)
+	if err != nil {
+		relPath = filepath.Base(moduleDir)
 	}
+	return filepath.Join(f.moduleCacheDir, signerCacheDir, relPath+signerFileSuffix)
+}
 
+// isCached reports whether the module is in the cache, and signed by a
+// trusted key when its remote has a trust policy.
+func (f *gitResolver) isCached(moduleIdentifier string, trust *GitTrustConfig) bool {
+	moduleDir := f.modulePathFromIdentifier(moduleIdentifier)
+	if !utils.DirExists(moduleDir) {
+		return false
+	}
+	if trust == nil {
+		return true
+	}
+	signer, err := os.ReadFile(f.signerPath(moduleDir))
+	return err == nil && trust.trusts(string(signer))
+}
+
+func (f *gitResolver) getModule(ctx context.Context, moduleIdentifier string) (string, error) {
 	// parse module identifier
 	mId := GitModuleIdentifier(moduleIdentifier)
-	remoteIdentifier, branch, version := mId.Remote(), mId.Branch(), mId.Version()
-	// handle branch if present
-	referenceName := plumbing.ReferenceName(branch)
-	if branch != "" {
-		referenceName = plumbing.NewBranchReferenceName(branch)
+	remoteIdentifier, branch, version, subpath := mId.Remote(), mId.Branch(), mId.Version(), mId.Subpath()
+	trust := trustFor(f.trust, remoteIdentifier)
+
+	// module already exists in module cache
+	if f.isCached(moduleIdentifier, trust) {
+		return moduleIdentifier, nil
 	}
-	// clone the module
-	log.Printf("Cloning: %s", moduleIdentifier)
-	cloneOptions := &git.CloneOptions{
-		URL:           buildRemoteURL(f.authMode, remoteIdentifier),
-		Progress:      os.Stderr,
-		Auth:          f.auth,
-		ReferenceName: referenceName,
+
+	if subpath != "" && !fs.ValidPath(subpath) {
+		return "", fmt.Errorf("%s: invalid subpath %q", moduleIdentifier, subpath)
+	}
+	remote, err := f.auth.forRemote(remoteIdentifier)
+	if err != nil {
+		return "", err
 	}
 
 	tmpDir, err := os.MkdirTemp("", "jnetx-")
@@ -73,15 +180,38 @@ func (f *gitResolver) getModule(moduleIdentifier string) (string, error) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	repo, err := git.PlainClone(tmpDir, false, cloneOptions)
-	if err != nil && err != git.ErrRepositoryAlreadyExists && !errors.Is(err, fs.ErrExist) {
-		return "", err
+	// exact versions need a single commit, anything else a pseudo-version
+	// computed from the history
+	var completeVersion, commitHash string
+	repo, shallow := f.shallowFetch(ctx, remote, moduleIdentifier, version, tmpDir)
+	if shallow != nil {
+		completeVersion, commitHash = shallow.tag, shallow.commit
+	} else {
+		repo, err = f.clone(remote, moduleIdentifier, branch, tmpDir)
+		if err != nil {
+			return "", err
+		}
+		tagCommits, err := f.tagCommits(ctx, remote)
+		if err != nil {
+			return "", err
+		}
+		tagCommits, err = peelTagCommits(repo, tagCommits)
+		if err != nil {
+			return "", err
+		}
+		// Find the complete version and commit hash
+		completeVersion, commitHash, err = findPseudoVersion(version, repo, tagCommits)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	// Find the complete version and commit hash
-	completeVersion, commitHash, err := f.findPseudoVersion(version, repo)
-	if err != nil {
-		return "", err
+	var signer string
+	if trust != nil {
+		signer, err = verifySignature(repo, trust, completeVersion, commitHash)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", moduleIdentifier, err)
+		}
 	}
 
 	// Get the worktree and checkout the specific commit
@@ -89,106 +219,255 @@ func (f *gitResolver) getModule(moduleIdentifier string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-
-	err = wt.Checkout(&git.CheckoutOptions{
+	checkoutOptions := &git.CheckoutOptions{
 		Hash:  plumbing.NewHash(commitHash),
 		Force: true,
-	})
+	}
+	if subpath != "" {
+		checkoutOptions.SparseCheckoutDirectories = []string{subpath}
+	}
+	if err := wt.Checkout(checkoutOptions); err != nil {
+		return "", err
+	}
+
+	// branches are kept to be updated to their next tip
+	if branch != "" {
+		remoteIdentifier = remoteIdentifier + "/" + branch
+	}
+	moduleDir := tmpDir
+	if subpath != "" {
+		moduleDir = filepath.Join(tmpDir, filepath.FromSlash(subpath))
+		if !utils.DirExists(moduleDir) {
+			return "", fmt.Errorf("%s: %w: no directory %s at %s", moduleIdentifier, ErrModuleNotFound, subpath, completeVersion)
+		}
+		subpath = SubpathSeparator + subpath
+	}
 
 	// copy to module files removing the .git directory
 	os.RemoveAll(path.Join(tmpDir, ".git"))
-	moduleIdentifier = fmt.Sprintf("%s@%s", remoteIdentifier, completeVersion)
+	moduleIdentifier = fmt.Sprintf("%s%s@%s", remoteIdentifier, subpath, completeVersion)
 	targetDir := f.modulePathFromIdentifier(moduleIdentifier)
 	os.RemoveAll(targetDir) // ensure target dir is clean
-	err = os.CopyFS(targetDir, os.DirFS(tmpDir))
+	if err := os.CopyFS(targetDir, os.DirFS(moduleDir)); err != nil {
+		return "", err
+	}
+	signerPath := f.signerPath(targetDir)
+	os.Remove(signerPath)
+	if signer != "" {
+		if err := os.MkdirAll(filepath.Dir(signerPath), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(signerPath, []byte(signer), 0644); err != nil {
+			return "", err
+		}
+		log.Printf("Verified signature of %s by %s", moduleIdentifier, signer)
+	}
 	log.Println("Module cloned", moduleIdentifier)
 
-	return moduleIdentifier, err
+	return moduleIdentifier, nil
 }
 
-func (f *gitResolver) findPseudoVersion(commitIdentifier string, repo *git.Repository) (string, string, error) {
-	if commitIdentifier == "" {
-		// If commitHashString is empty, get the latest commit hash from HEAD
-		head, err := repo.Head()
-		if err != nil {
-			return "", "", err
-		}
-		commitIdentifier = head.Hash().String()
-	} else if tagRef, err := repo.Tag(commitIdentifier); err == nil {
-		// If commitHashString is a tag, resolve it to a commit hash
-		tag, err := repo.Object(plumbing.AnyObject, tagRef.Hash())
-		if err != nil {
-			return "", "", err
-		}
+// clone fetches the full history of the remote, or of branch if not empty.
+func (f *gitResolver) clone(remote *gitRemote, moduleIdentifier, branch, dir string) (*git.Repository, error) {
+	// handle branch if present
+	referenceName := plumbing.ReferenceName(branch)
+	if branch != "" {
+		referenceName = plumbing.NewBranchReferenceName(branch)
+	}
+	log.Printf("Cloning: %s", moduleIdentifier)
+	cloneOptions := &git.CloneOptions{
+		URL:           remote.url,
+		Progress:      os.Stderr,
+		Auth:          remote.method,
+		ReferenceName: referenceName,
+		NoCheckout:    true,
+	}
+	repo, err := git.PlainClone(dir, false, cloneOptions)
+	if err != nil && err != git.ErrRepositoryAlreadyExists && !errors.Is(err, fs.ErrExist) {
+		return nil, err
+	}
+	return repo, nil
+}
 
-		commitIdentifier, err = resolveObjectToCommitHash(tag, repo)
-		if err != nil {
-			return "", "", err
-		}
-	} else if goModule.IsPseudoVersion(commitIdentifier) {
-		// Handle pseudo-versions getting only the commit hash
+// shallowTarget is a commit whose version, a semver tag of the commit, is
+// known without its history.
+type shallowTarget struct {
+	tag    string
+	commit string
+}
 
-		commitIdentifier, err = goModule.PseudoVersionRev(commitIdentifier)
-		if err != nil {
-			return "", "", err
-		}
+// shallowFetch fetches only the commit of version when it is a semver tag, or
+// a full commit hash carrying one. It returns a nil target when the version
+// needs the history, or when the remote does not serve shallow fetches.
+func (f *gitResolver) shallowFetch(ctx context.Context, remote *gitRemote, moduleIdentifier, version, dir string) (*git.Repository, *shallowTarget) {
+	if !semver.IsValid(version) && !isFullCommitHash(version) {
+		return nil, nil
+	}
+	tagCommits, err := f.tagCommits(ctx, remote)
+	if err != nil {
+		// the clone reports the error of the remote, if any
+		return nil, nil
+	}
+	target := findShallowTarget(tagCommits, version)
+	if target == nil {
+		return nil, nil
 	}
 
-	tagCommitMap, err := getCommitHashTagMap(repo)
+	log.Printf("Fetching: %s", moduleIdentifier)
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		return nil, nil
+	}
+	refSpec := config.RefSpec(fmt.Sprintf("+refs/tags/%s:refs/tags/%[1]s", target.tag))
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name:  git.DefaultRemoteName,
+		URLs:  []string{remote.url},
+		Fetch: []config.RefSpec{refSpec},
+	})
+	if err == nil {
+		err = repo.Fetch(&git.FetchOptions{
+			RemoteName: git.DefaultRemoteName,
+			RefSpecs:   []config.RefSpec{refSpec},
+			Depth:      1,
+			Tags:       git.NoTags,
+			Auth:       remote.method,
+			Progress:   os.Stderr,
+		})
+	}
+	if err != nil {
+		log.Printf("Shallow fetch of %s failed, cloning: %v", moduleIdentifier, err)
+		os.RemoveAll(filepath.Join(dir, git.GitDirName))
+		return nil, nil
+	}
+	// remotes may list annotated tags without the commit they point to
+	commit, err := resolveCommit(repo, plumbing.NewTagReferenceName(target.tag).String())
+	if err != nil {
+		os.RemoveAll(filepath.Join(dir, git.GitDirName))
+		return nil, nil
+	}
+	target.commit = commit.Hash.String()
+	return repo, target
+}
+
+// findShallowTarget returns the tagged commit version designates: the tag
+// itself, or the lowest semver tag of a full commit hash.
+func findShallowTarget(tagCommits map[string]string, version string) *shallowTarget {
+	if commit, ok := tagCommits[version]; ok && semver.IsValid(version) {
+		return &shallowTarget{tag: version, commit: commit}
+	}
+	tags, ok := semverTagsByCommit(tagCommits)[version]
+	if !ok {
+		return nil
+	}
+	return &shallowTarget{tag: tags[0], commit: version}
+}
+
+// tagCommits returns the commit each tag of the remote points to, listing
+// the remote only once.
+func (f *gitResolver) tagCommits(ctx context.Context, remote *gitRemote) (map[string]string, error) {
+	f.tagCacheMu.Lock()
+	defer f.tagCacheMu.Unlock()
+	if tagCommits, ok := f.tagCache[remote.url]; ok {
+		return tagCommits, nil
+	}
+	tagCommits, err := listTagCommits(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	if f.tagCache == nil {
+		f.tagCache = make(map[string]map[string]string)
+	}
+	f.tagCache[remote.url] = tagCommits
+	return tagCommits, nil
+}
+
+// findPseudoVersion returns the version of the commit designated by
+// commitIdentifier, or HEAD if empty, and its hash: the lowest semver tag of
+// the commit, or else a pseudo-version based on the highest semver tag
+// reachable from it.
+func findPseudoVersion(commitIdentifier string, repo *git.Repository, tagCommits map[string]string) (string, string, error) {
+	commit, err := resolveCommit(repo, commitIdentifier)
 	if err != nil {
 		return "", "", err
 	}
+	commitHash := commit.Hash.String()
 
-	gitLog, err := repo.Log(&git.LogOptions{
-		Order: git.LogOrderCommitterTime,
-	})
+	commitTags := semverTagsByCommit(tagCommits)
+	if tags, ok := commitTags[commitHash]; ok {
+		// Exact match with a semver tag
+		return tags[0], commitHash, nil
+	}
+
+	baseTag, err := highestReachableTag(repo, commit, commitTags)
 	if err != nil {
 		return "", "", err
 	}
-	defer gitLog.Close()
+	if baseTag == "" {
+		return goModule.PseudoVersion("v0", "", commit.Committer.When, commitHash[:12]), commitHash, nil
+	}
+	return goModule.PseudoVersion("", baseTag, commit.Committer.When, commitHash[:12]), commitHash, nil
+}
 
-	var baseCommit *object.Commit
+// highestReachableTag walks the ancestors of commit breadth first for the
+// highest of their semver tags, stopping early once the highest tag of the
+// repository is found.
+func highestReachableTag(repo *git.Repository, commit *object.Commit, commitTags map[string][]string) (string, error) {
+	highest := ""
+	for _, tags := range commitTags {
+		if tag := tags[len(tags)-1]; highest == "" || semver.Compare(tag, highest) > 0 {
+			highest = tag
+		}
+	}
+	if highest == "" {
+		return "", nil
+	}
 
-	for {
-		commit, err := gitLog.Next()
-		if err != nil {
-			break
-		}
-		// look for the commit that matches the prefix
-		if baseCommit == nil && strings.HasPrefix(commit.Hash.String(), commitIdentifier) {
-			baseCommit = commit
-			// Check if this commit has any semver tags
-			if tagnames, ok := tagCommitMap[commit.Hash.String()]; ok {
-				// Exact match with a semver tag
-				semver.Sort(tagnames)
-				return tagnames[0], commit.Hash.String(), nil
+	best := ""
+	seen := map[plumbing.Hash]bool{commit.Hash: true}
+	queue := append([]plumbing.Hash(nil), commit.ParentHashes...)
+	for _, hash := range queue {
+		seen[hash] = true
+	}
+	for walked := 0; len(queue) > 0; walked++ {
+		if walked == maxBaseTagWalk {
+			return "", fmt.Errorf("no base tag for %s within %d commits, use a tag instead", commit.Hash, maxBaseTagWalk)
+		}
+		hash := queue[0]
+		queue = queue[1:]
+		if tags, ok := commitTags[hash.String()]; ok {
+			if tag := tags[len(tags)-1]; best == "" || semver.Compare(tag, best) > 0 {
+				best = tag
 			}
+			if best == highest {
+				return best, nil
+			}
+		}
+		ancestor, err := repo.CommitObject(hash)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			// history cut by a shallow fetch
+			continue
 		}
-		// If we have found the base commit, look for the nearest semver tag in the history
-		if baseCommit != nil {
-			if tagnames, ok := tagCommitMap[commit.Hash.String()]; ok {
-				semver.Sort(tagnames)
-				pseudoVersion := goModule.PseudoVersion("", tagnames[0], baseCommit.Committer.When, commitIdentifier[0:12])
-				return pseudoVersion, baseCommit.Hash.String(), nil
+		if err != nil {
+			return "", err
+		}
+		for _, parent := range ancestor.ParentHashes {
+			if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
 			}
 		}
 	}
-	if baseCommit == nil {
-		return "", "", fmt.Errorf("commit hash %s not found", commitIdentifier)
-	}
-	pseudoVersion := goModule.PseudoVersion("v0", "", baseCommit.Committer.When, commitIdentifier[0:12])
-	return pseudoVersion, baseCommit.Hash.String(), nil
+	return best, nil
 }
 
 func NewGitResolver(targetDir string) (custodian.Resolver, error) {
-	authMode, auth, err := getAuthMethodFromEnv()
+	config, err := LoadConfig()
 	if err != nil {
 		return nil, err
 	}
-
 	return &gitResolver{
-		auth:           auth,
-		authMode:       authMode,
+		auth:           newGitAuthProvider(config),
+		trust:          config.Git.Trust,
 		moduleCacheDir: targetDir,
 	}, nil
 }
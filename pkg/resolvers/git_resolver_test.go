@@ -1,77 +1,186 @@
 package resolvers
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/internal/gittest"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
 )
 
-func Test_gitResolver_getModule(t *testing.T) {
+var (
+	commitTime1 = time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	commitTime2 = time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+	commitTime3 = time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	commitTime4 = time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+)
 
-	dir, err := os.MkdirTemp("", "gittest")
+// newTestGitResolver returns a git resolver fetching the remotes of the host
+// of serverURL over plain HTTP, and that host.
+func newTestGitResolver(t *testing.T, serverURL string) (*gitResolver, string) {
+	t.Helper()
+	parsedURL, err := url.Parse(serverURL)
 	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
+		t.Fatalf("Invalid server URL: %v", err)
 	}
-	defer os.RemoveAll(dir)
-	f, err := NewGitResolver(dir)
-	if err != nil {
-		t.Fatalf("Failed to create GitResolver: %v", err)
-	}
-	gf := f.(*gitResolver)
+	port, _ := strconv.Atoi(parsedURL.Port())
+	config := &Config{Git: GitConfig{Hosts: []GitHostConfig{
+		{Host: parsedURL.Hostname(), AuthMode: GitAuthModeNone, Scheme: GitSchemeHttp, Port: port},
+	}}}
+	return &gitResolver{
+		auth:           newGitAuthProvider(config),
+		moduleCacheDir: t.TempDir(),
+	}, parsedURL.Hostname()
+}
+
+func Test_gitResolver_getModule(t *testing.T) {
+	// lib: v1.0.0 (lightweight) <- c2 <- v1.1.0 (annotated) <- c4 (main)
+	//                                  \- feature
+	lib := gittest.NewRepo(t)
+	c1 := lib.Commit(commitTime1, map[string]string{"main.libsonnet": "{ v: 1 }", "sub/sub.libsonnet": "{}"})
+	lib.Tag("v1.0.0", c1)
+	c2 := lib.Commit(commitTime2, map[string]string{"main.libsonnet": "{ v: 2 }"})
+	c3 := lib.Commit(commitTime3, map[string]string{"main.libsonnet": "{ v: 3 }"})
+	lib.AnnotatedTag("v1.1.0", c3, commitTime3)
+	lib.Branch("feature")
+	feature := lib.Commit(commitTime4, map[string]string{"feature.libsonnet": "{}"})
+	lib.Checkout("main")
+	c4 := lib.Commit(commitTime4, map[string]string{"main.libsonnet": "{ v: 4 }"})
+
+	untagged := gittest.NewRepo(t)
+	head := untagged.Commit(commitTime1, map[string]string{"main.libsonnet": "{}"})
+
+	// graph: v1.0.0 <- v0.9.0 <- g2 (main)
+	//               \- v3.0.0 <- o2 (other), committed after v0.9.0
+	graph := gittest.NewRepo(t)
+	g1 := graph.Commit(commitTime1, map[string]string{"main.libsonnet": "{}"})
+	graph.Tag("v1.0.0", g1)
+	graph.Branch("other")
+	o1 := graph.Commit(commitTime3, map[string]string{"other.libsonnet": "{}"})
+	graph.Tag("v3.0.0", o1)
+	o2 := graph.Commit(commitTime4, map[string]string{"other.libsonnet": "{ a: 1 }"})
+	graph.Checkout("main")
+	g2 := graph.Commit(commitTime2, map[string]string{"main.libsonnet": "{ a: 1 }"})
+	graph.Tag("v0.9.0", g2)
+	g3 := graph.Commit(commitTime4, map[string]string{"main.libsonnet": "{ a: 2 }"})
+
+	server := gittest.Serve(t, map[string]*gittest.Repo{"org/lib": lib, "org/untagged": untagged, "org/graph": graph})
+	gf, host := newTestGitResolver(t, server.URL)
 
 	tests := []struct {
 		name string // description of this test case
 		// Named input parameters for target function.
 		moduleIdentifier string
 		want             string
+		wantFile         string
 		wantErr          bool
 	}{
 		{
 			name:             "Test cloning a valid git module with tag version",
-			moduleIdentifier: "github.com/google/go-jsonnet@v0.21.0",
-			want:             "github.com/google/go-jsonnet@v0.21.0",
-			wantErr:          false,
+			moduleIdentifier: host + "/org/lib@v1.0.0",
+			want:             host + "/org/lib@v1.0.0",
+		},
+		{
+			name:             "Test cloning a valid git module with annotated tag version",
+			moduleIdentifier: host + "/org/lib@v1.1.0",
+			want:             host + "/org/lib@v1.1.0",
 		},
 		{
 			name:             "Test cloning a valid git module with commit hash matching a tag",
-			moduleIdentifier: "github.com/google/go-jsonnet@67968688d9952f506",
-			want:             "github.com/google/go-jsonnet@v0.21.0",
-			wantErr:          false,
+			moduleIdentifier: host + "/org/lib@" + c1.String()[:16],
+			want:             host + "/org/lib@v1.0.0",
 		},
 		{
 			name:             "Test cloning a valid git module from HEAD",
-			moduleIdentifier: "github.com/getsops/gopgagent",
-			want:             "github.com/getsops/gopgagent@v0.0.0-20241224165529-7044f28e491e",
-			wantErr:          false,
+			moduleIdentifier: host + "/org/untagged",
+			want:             host + "/org/untagged@v0.0.0-20250101100000-" + head.String()[:12],
+		},
+		{
+			name:             "Test cloning a valid git module from HEAD after a tag",
+			moduleIdentifier: host + "/org/lib",
+			want:             host + "/org/lib@v1.1.1-0.20250401100000-" + c4.String()[:12],
 		},
 		{
 			name:             "Test cloning a valid git module with commit hash not matching a tag",
-			moduleIdentifier: "github.com/go-git/gcfg@0495f244e4712b22418b489b2079d3a1598f434f",
-			want:             "github.com/go-git/gcfg@v2.0.2-0.20250606171425-0495f244e471",
-			wantErr:          false,
+			moduleIdentifier: host + "/org/lib@" + c2.String(),
+			want:             host + "/org/lib@v1.0.1-0.20250201100000-" + c2.String()[:12],
+			wantFile:         "main.libsonnet",
 		},
 		{
 			name:             "Test cloning a valid git module with a long pseudo-version",
-			moduleIdentifier: "github.com/go-git/gcfg@v2.0.1-0.20240810071503-633fd26b3faaa8e7a2548e4b4b0b1a07618eba86",
-			want:             "github.com/go-git/gcfg@v2.0.1-0.20240810071503-633fd26b3faa",
-			wantErr:          false,
+			moduleIdentifier: host + "/org/lib@v1.0.1-0.20250201100000-" + c2.String(),
+			want:             host + "/org/lib@v1.0.1-0.20250201100000-" + c2.String()[:12],
 		},
 		{
 			name:             "Test cloning a valid git module with a valid pseudo-version",
-			moduleIdentifier: "github.com/go-git/gcfg@v2.0.1-0.20240810071503-633fd26b3faa",
-			want:             "github.com/go-git/gcfg@v2.0.1-0.20240810071503-633fd26b3faa",
-			wantErr:          false,
+			moduleIdentifier: host + "/org/lib@v1.0.1-0.20250201100000-" + c2.String()[:12],
+			want:             host + "/org/lib@v1.0.1-0.20250201100000-" + c2.String()[:12],
 		},
 		{
-			name:             "Test cloning a valid git module with a valid pseudo-version",
-			moduleIdentifier: "github.com/go-git/gcfg@v2.0.1-0.20240810071503-633as26b3faa",
-			want:             "",
+			name:             "Test cloning a valid git module from a branch",
+			moduleIdentifier: host + "/org/lib/feature",
+			want:             host + "/org/lib/feature@v1.1.1-0.20250401100000-" + feature.String()[:12],
+			wantFile:         "feature.libsonnet",
+		},
+		{
+			name:             "Test cloning a valid git module from a branch pinned to a commit",
+			moduleIdentifier: host + "/org/lib/feature@" + c3.String(),
+			want:             host + "/org/lib/feature@v1.1.0",
+		},
+		{
+			name:             "Test cloning a valid git module based on the highest reachable tag",
+			moduleIdentifier: host + "/org/graph@" + g3.String(),
+			want:             host + "/org/graph@v1.0.1-0.20250401100000-" + g3.String()[:12],
+		},
+		{
+			name:             "Test cloning a valid git module with commit hash outside of HEAD history",
+			moduleIdentifier: host + "/org/graph@" + o2.String()[:12],
+			want:             host + "/org/graph@v3.0.1-0.20250401100000-" + o2.String()[:12],
+		},
+		{
+			name:             "Test cloning a subpath of a valid git module",
+			moduleIdentifier: host + "/org/lib//sub@v1.1.0",
+			want:             host + "/org/lib//sub@v1.1.0",
+			wantFile:         "sub.libsonnet",
+		},
+		{
+			name:             "Test cloning a subpath of a valid git module from HEAD",
+			moduleIdentifier: host + "/org/lib//sub",
+			want:             host + "/org/lib//sub@v1.1.1-0.20250401100000-" + c4.String()[:12],
+			wantFile:         "sub.libsonnet",
+		},
+		{
+			name:             "Test cloning a missing subpath of a valid git module",
+			moduleIdentifier: host + "/org/lib//missing@v1.1.0",
+			wantErr:          true,
+		},
+		{
+			name:             "Test cloning a subpath escaping the repository",
+			moduleIdentifier: host + "/org/lib//../sub@v1.1.0",
+			wantErr:          true,
+		},
+		{
+			name:             "Test cloning a valid git module with an invalid pseudo-version",
+			moduleIdentifier: host + "/org/lib@v1.0.1-0.20250201100000-633as26b3faa",
+			wantErr:          true,
+		},
+		{
+			name:             "Test cloning an unknown git module",
+			moduleIdentifier: host + "/org/missing@v1.0.0",
 			wantErr:          true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// TODO: construct the receiver type.
-			got, gotErr := gf.getModule(tt.moduleIdentifier)
+			got, gotErr := gf.getModule(t.Context(), tt.moduleIdentifier)
 			if gotErr != nil {
 				if !tt.wantErr {
 					t.Errorf("getModule() failed: %v", gotErr)
@@ -84,7 +193,232 @@ func Test_gitResolver_getModule(t *testing.T) {
 			if got != tt.want {
 				t.Errorf("getModule() = %v, want %v", got, tt.want)
 			}
+			if tt.wantFile != "" {
+				if _, err := os.Stat(filepath.Join(gf.modulePathFromIdentifier(got), tt.wantFile)); err != nil {
+					t.Errorf("module file %s: %v", tt.wantFile, err)
+				}
+			}
+		})
+	}
+}
+
+func Test_gitResolver_Resolve_branchTip(t *testing.T) {
+	// lib: v1.0.0 (main) <- f1 <- f2 (feature), f2 committed after the
+	// first resolution
+	lib := gittest.NewRepo(t)
+	c1 := lib.Commit(commitTime1, map[string]string{"main.libsonnet": "{ v: 1 }"})
+	lib.Tag("v1.0.0", c1)
+	lib.Branch("feature")
+	f1 := lib.Commit(commitTime2, map[string]string{"main.libsonnet": "{ v: 2 }"})
+
+	server := gittest.Serve(t, map[string]*gittest.Repo{"org/lib": lib})
+	gf, host := newTestGitResolver(t, server.URL)
+
+	// lockEntry returns the lock entry of a root module requiring
+	// moduleIdentifier, as written by custodian mod update.
+	lockEntry := func(moduleIdentifier string) modules.LockEntry {
+		t.Helper()
+		root, err := modules.NewModuleFromFS(".", fstest.MapFS{
+			modules.ModuleFileName: {Data: []byte(fmt.Sprintf(`{"require": {"lib": %q}}`, moduleIdentifier))},
+		})
+		if err != nil {
+			t.Fatalf("Failed to load root module: %v", err)
+		}
+		dependencyTree, err := modules.NewDependencyTree(root, gf)
+		if err != nil {
+			t.Fatalf("Failed to build dependency tree: %v", err)
+		}
+		data, err := dependencyTree.GenerateLockFile()
+		if err != nil {
+			t.Fatalf("GenerateLockFile() failed: %v", err)
+		}
+		lockData := &modules.LockFile{}
+		if err := json.Unmarshal(data, lockData); err != nil || len(lockData.Modules) != 1 {
+			t.Fatalf("invalid lock file %s: %v", data, err)
+		}
+		return lockData.Modules[0]
+	}
+
+	first, err := gf.Resolve(t.Context(), host+"/org/lib/feature")
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	wantFirst := host + "/org/lib/feature@v1.0.1-0.20250201100000-" + f1.String()[:12]
+	if first.Identifier() != wantFirst {
+		t.Fatalf("Resolve() = %v, want %v", first.Identifier(), wantFirst)
+	}
+	if entry := lockEntry(first.Identifier()); entry.Module != wantFirst || entry.Branch != "feature" {
+		t.Errorf("lock entry = %+v, want %s on branch feature", entry, wantFirst)
+	}
+
+	f2 := lib.Commit(commitTime3, map[string]string{"main.libsonnet": "{ v: 3 }"})
+	tip, tracking := BranchTip(first.Identifier())
+	if !tracking {
+		t.Fatalf("BranchTip(%q) tracks no branch", first.Identifier())
+	}
+	updated, err := gf.Resolve(t.Context(), tip)
+	if err != nil {
+		t.Fatalf("Resolve() of the tip failed: %v", err)
+	}
+	wantUpdated := host + "/org/lib/feature@v1.0.1-0.20250301100000-" + f2.String()[:12]
+	if updated.Identifier() != wantUpdated {
+		t.Errorf("Resolve() of the tip = %v, want %v", updated.Identifier(), wantUpdated)
+	}
+	if entry := lockEntry(updated.Identifier()); entry.Module != wantUpdated || entry.Branch != "feature" {
+		t.Errorf("lock entry = %+v, want %s on branch feature", entry, wantUpdated)
+	}
+}
+
+func Test_gitResolver_getModule_local(t *testing.T) {
+	gittest.RequireGit(t)
+	repo := gittest.NewRepo(t)
+	tagged := repo.Commit(commitTime1, map[string]string{"main.libsonnet": "{ a: 1 }"})
+	repo.Tag("v1.0.0", tagged)
+	head := repo.Commit(commitTime2, map[string]string{"main.libsonnet": "{ a: 2 }"})
+
+	f, err := NewGitResolver(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create GitResolver: %v", err)
+	}
+	gf := f.(*gitResolver)
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		want             string
+	}{
+		{
+			name:             "file URL with tag",
+			moduleIdentifier: repo.URL() + "@v1.0.0",
+			want:             repo.URL() + "@v1.0.0",
+		},
+		{
+			name:             "path with commit hash",
+			moduleIdentifier: repo.Dir + "@" + head.String(),
+			want:             repo.Dir + "@v1.0.1-0.20250201100000-" + head.String()[:12],
+		},
+		{
+			name:             "file URL at HEAD",
+			moduleIdentifier: repo.URL(),
+			want:             repo.URL() + "@v1.0.1-0.20250201100000-" + head.String()[:12],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := gf.getModule(t.Context(), tt.moduleIdentifier)
+			if gotErr != nil {
+				t.Fatalf("getModule() failed: %v", gotErr)
+			}
+			if got != tt.want {
+				t.Errorf("getModule() = %v, want %v", got, tt.want)
+			}
+			modulePath := gf.modulePathFromIdentifier(got)
+			if !strings.HasPrefix(modulePath, gf.moduleCacheDir) {
+				t.Errorf("module cached outside of the cache: %s", modulePath)
+			}
+			if _, err := os.Stat(filepath.Join(modulePath, "main.libsonnet")); err != nil {
+				t.Errorf("module not in cache: %v", err)
+			}
 		})
 	}
-	// t.Error(dir)
+}
+
+func Test_gitResolver_shallowFetch(t *testing.T) {
+	// served by git-upload-pack, as the go-git server has no shallow support
+	gittest.RequireGit(t)
+	repo := gittest.NewRepo(t)
+	c1 := repo.Commit(commitTime1, map[string]string{"main.libsonnet": "{ a: 1 }"})
+	c2 := repo.Commit(commitTime2, map[string]string{"main.libsonnet": "{ a: 2 }"})
+	repo.Tag("v1.0.1", c2)
+	repo.AnnotatedTag("v1.0.0", c2, commitTime2)
+	c3 := repo.Commit(commitTime3, map[string]string{"main.libsonnet": "{ a: 3 }"})
+
+	gf := &gitResolver{auth: newGitAuthProvider(&Config{}), moduleCacheDir: t.TempDir()}
+	remote := &gitRemote{url: repo.URL()}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		version    string
+		wantTag    string
+		wantCommit string
+	}{
+		{
+			name:       "lightweight tag",
+			version:    "v1.0.1",
+			wantTag:    "v1.0.1",
+			wantCommit: c2.String(),
+		},
+		{
+			name:       "annotated tag",
+			version:    "v1.0.0",
+			wantTag:    "v1.0.0",
+			wantCommit: c2.String(),
+		},
+		{
+			name:       "full commit hash of tags",
+			version:    c2.String(),
+			wantTag:    "v1.0.0",
+			wantCommit: c2.String(),
+		},
+		{
+			name:    "full commit hash without tag",
+			version: c3.String(),
+		},
+		{
+			name:    "short commit hash",
+			version: c2.String()[:12],
+		},
+		{
+			name:    "pseudo-version",
+			version: "v1.0.2-0.20250301100000-" + c3.String()[:12],
+		},
+		{
+			name: "HEAD",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, target := gf.shallowFetch(t.Context(), remote, repo.URL(), tt.version, t.TempDir())
+			if tt.wantTag == "" {
+				if target != nil {
+					t.Errorf("shallowFetch() = %v, want a full clone", target)
+				}
+				return
+			}
+			if target == nil {
+				t.Fatal("shallowFetch() fell back to a full clone")
+			}
+			if target.tag != tt.wantTag || target.commit != tt.wantCommit {
+				t.Errorf("shallowFetch() = %v, want %s at %s", target, tt.wantTag, tt.wantCommit)
+			}
+			shallows, err := got.Storer.Shallow()
+			if err != nil || len(shallows) != 1 || shallows[0].String() != tt.wantCommit {
+				t.Errorf("shallow commits = %v, %v, want %s", shallows, err, tt.wantCommit)
+			}
+			if _, err := got.CommitObject(c1); err == nil {
+				t.Errorf("shallowFetch() fetched the history")
+			}
+		})
+	}
+}
+
+func Test_gitResolver_ListVersions(t *testing.T) {
+	repo := gittest.NewRepo(t)
+	c1 := repo.Commit(commitTime1, nil)
+	repo.Tag("v1.0.0", c1)
+	repo.Tag("not-semver", c1)
+	c2 := repo.Commit(commitTime2, nil)
+	repo.AnnotatedTag("v1.1.0-rc.1", c2, commitTime2)
+	server := gittest.Serve(t, map[string]*gittest.Repo{"org/lib": repo})
+	gf, host := newTestGitResolver(t, server.URL)
+
+	got, err := gf.ListVersions(t.Context(), host+"/org/lib")
+	if err != nil {
+		t.Fatalf("ListVersions() failed: %v", err)
+	}
+	if strings.Join(got, ",") != "v1.0.0,v1.1.0-rc.1" {
+		t.Errorf("ListVersions() = %v, want [v1.0.0 v1.1.0-rc.1]", got)
+	}
 }
@@ -0,0 +1,228 @@
+package resolvers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// signerFileSuffix names the file beside a cached module recording the
+	// key its tag or commit was verified with.
+	signerFileSuffix = ".signer"
+
+	openPGPSignerPrefix = "openpgp:"
+	sshSignerPrefix     = "ssh:"
+
+	sshSignatureMagic     = "SSHSIG"
+	sshSignatureNamespace = "git"
+)
+
+var ErrUntrustedSignature = errors.New("untrusted signature")
+
+// trustFor returns the trust policy of remoteIdentifier, the first whose
+// Remote glob matches, or nil if its signatures are not verified.
+func trustFor(trust []GitTrustConfig, remoteIdentifier string) *GitTrustConfig {
+	for i := range trust {
+		if matched, _ := path.Match(trust[i].Remote, remoteIdentifier); matched {
+			return &trust[i]
+		}
+	}
+	return nil
+}
+
+// trusts reports whether signer, as recorded by verifySignature, is one of
+// the keys of the policy.
+func (c *GitTrustConfig) trusts(signer string) bool {
+	if fingerprint, found := strings.CutPrefix(signer, sshSignerPrefix); found {
+		for _, authorizedKey := range c.SSHKeys {
+			key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+			if err == nil && ssh.FingerprintSHA256(key) == fingerprint {
+				return true
+			}
+		}
+		return false
+	}
+	fingerprint, found := strings.CutPrefix(signer, openPGPSignerPrefix)
+	if !found {
+		return false
+	}
+	keyring, err := c.keyring()
+	if err != nil {
+		return false
+	}
+	for _, entity := range keyring {
+		if strings.EqualFold(hex.EncodeToString(entity.PrimaryKey.Fingerprint), fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *GitTrustConfig) keyring() (openpgp.EntityList, error) {
+	var keyring openpgp.EntityList
+	for _, armoredKey := range c.OpenPGPKeys {
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OpenPGP key for %s: %w", c.Remote, err)
+		}
+		keyring = append(keyring, entities...)
+	}
+	return keyring, nil
+}
+
+// signedObject is a tag or commit with its signature.
+type signedObject interface {
+	EncodeWithoutSignature(o plumbing.EncodedObject) error
+}
+
+// verifySignature verifies the signature of the annotated tag version, or
+// else of the commit commitHash, against the keys of the policy, and returns
+// the signing key.
+func verifySignature(repo *git.Repository, trust *GitTrustConfig, version, commitHash string) (string, error) {
+	var obj signedObject
+	var signature, objectName string
+	if tagRef, err := repo.Tag(version); err == nil {
+		if tag, err := repo.TagObject(tagRef.Hash()); err == nil {
+			obj, signature, objectName = tag, tag.PGPSignature, "tag "+version
+		}
+	}
+	if obj == nil {
+		commit, err := repo.CommitObject(plumbing.NewHash(commitHash))
+		if err != nil {
+			return "", err
+		}
+		obj, signature, objectName = commit, commit.PGPSignature, "commit "+commitHash
+	}
+	if signature == "" {
+		return "", fmt.Errorf("%w: %s is not signed", ErrUntrustedSignature, objectName)
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := obj.EncodeWithoutSignature(encoded); err != nil {
+		return "", err
+	}
+	payload, err := encoded.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer payload.Close()
+
+	var signer string
+	if strings.HasPrefix(signature, "-----BEGIN SSH SIGNATURE-----") {
+		signer, err = verifySSHSignature(trust.SSHKeys, payload, signature)
+	} else {
+		signer, err = verifyOpenPGPSignature(trust, payload, signature)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %w", ErrUntrustedSignature, objectName, err)
+	}
+	return signer, nil
+}
+
+func verifyOpenPGPSignature(trust *GitTrustConfig, payload io.Reader, signature string) (string, error) {
+	keyring, err := trust.keyring()
+	if err != nil {
+		return "", err
+	}
+	entity, err := openpgp.CheckArmoredDetachedSignature(keyring, payload, strings.NewReader(signature), nil)
+	if err != nil {
+		return "", err
+	}
+	return openPGPSignerPrefix + strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)), nil
+}
+
+// sshSignature is the blob of an armored SSH signature, as created by
+// ssh-keygen -Y sign.
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the data an SSH signature signs.
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+func verifySSHSignature(authorizedKeys []string, payload io.Reader, signature string) (string, error) {
+	block, _ := pem.Decode([]byte(signature))
+	if block == nil || block.Type != "SSH SIGNATURE" {
+		return "", errors.New("invalid SSH signature armor")
+	}
+	blob, found := bytes.CutPrefix(block.Bytes, []byte(sshSignatureMagic))
+	if !found {
+		return "", errors.New("invalid SSH signature")
+	}
+	sig := &sshSignature{}
+	if err := ssh.Unmarshal(blob, sig); err != nil {
+		return "", fmt.Errorf("invalid SSH signature: %w", err)
+	}
+	if sig.Version != 1 || sig.Namespace != sshSignatureNamespace {
+		return "", fmt.Errorf("unsupported SSH signature version %d or namespace %q", sig.Version, sig.Namespace)
+	}
+	publicKey, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	if !isAuthorizedKey(authorizedKeys, publicKey) {
+		return "", fmt.Errorf("key %s is not trusted", ssh.FingerprintSHA256(publicKey))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("unsupported SSH signature hash %q", sig.HashAlgorithm)
+	}
+	if _, err := io.Copy(h, payload); err != nil {
+		return "", err
+	}
+	signedData := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+
+	sshSig := &ssh.Signature{}
+	if err := ssh.Unmarshal(sig.Signature, sshSig); err != nil {
+		return "", fmt.Errorf("invalid SSH signature: %w", err)
+	}
+	if err := publicKey.Verify(signedData, sshSig); err != nil {
+		return "", err
+	}
+	return sshSignerPrefix + ssh.FingerprintSHA256(publicKey), nil
+}
+
+func isAuthorizedKey(authorizedKeys []string, publicKey ssh.PublicKey) bool {
+	for _, authorizedKey := range authorizedKeys {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+		if err == nil && bytes.Equal(key.Marshal(), publicKey.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
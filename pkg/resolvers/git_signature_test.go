@@ -0,0 +1,145 @@
+package resolvers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/internal/gittest"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"golang.org/x/crypto/ssh"
+)
+
+func newOpenPGPKey(t *testing.T) (*openpgp.Entity, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("maintainer", "", "maintainer@example.com", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate OpenPGP key: %v", err)
+	}
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("Failed to armor OpenPGP key: %v", err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatalf("Failed to serialize OpenPGP key: %v", err)
+	}
+	w.Close()
+	return entity, armored.String()
+}
+
+func newSSHKey(t *testing.T) (ssh.Signer, string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate SSH key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("Failed to create SSH signer: %v", err)
+	}
+	return signer, string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+}
+
+func Test_gitResolver_Resolve_signatures(t *testing.T) {
+	pgpKey, pgpPublicKey := newOpenPGPKey(t)
+	untrustedPGPKey, _ := newOpenPGPKey(t)
+	sshKey, sshPublicKey := newSSHKey(t)
+	untrustedSSHKey, _ := newSSHKey(t)
+	pgpSigner := openPGPSignerPrefix + strings.ToUpper(hex.EncodeToString(pgpKey.PrimaryKey.Fingerprint))
+	sshSigner := sshSignerPrefix + ssh.FingerprintSHA256(sshKey.PublicKey())
+
+	lib := gittest.NewRepo(t)
+	c1 := lib.SignedCommit(commitTime1, map[string]string{"main.libsonnet": "{ v: 1 }"}, gittest.OpenPGPSigner{Entity: pgpKey})
+	lib.SignedTag("v1.0.0", c1, commitTime1, gittest.OpenPGPSigner{Entity: pgpKey})
+	c2 := lib.SignedCommit(commitTime2, map[string]string{"main.libsonnet": "{ v: 2 }"}, gittest.SSHSigner{Key: sshKey})
+	lib.SignedTag("v1.1.0", c2, commitTime2, gittest.SSHSigner{Key: sshKey})
+	lib.Tag("v1.2.0", c2)
+	c3 := lib.Commit(commitTime3, map[string]string{"main.libsonnet": "{ v: 3 }"})
+	lib.Tag("v1.3.0", c3)
+	lib.SignedTag("v2.0.0", c3, commitTime3, gittest.OpenPGPSigner{Entity: untrustedPGPKey})
+	lib.SignedCommit(commitTime4, map[string]string{"main.libsonnet": "{ v: 4 }"}, gittest.SSHSigner{Key: untrustedSSHKey})
+
+	server := gittest.Serve(t, map[string]*gittest.Repo{"org/lib": lib, "other/lib": lib})
+	gf, host := newTestGitResolver(t, server.URL)
+	gf.trust = []GitTrustConfig{
+		{Remote: host + "/org/*", OpenPGPKeys: []string{pgpPublicKey}, SSHKeys: []string{sshPublicKey}},
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		wantSigner       string
+		wantErr          bool
+	}{
+		{
+			name:             "tag signed with a trusted OpenPGP key",
+			moduleIdentifier: host + "/org/lib@v1.0.0",
+			wantSigner:       pgpSigner,
+		},
+		{
+			name:             "tag signed with a trusted SSH key",
+			moduleIdentifier: host + "/org/lib@v1.1.0",
+			wantSigner:       sshSigner,
+		},
+		{
+			name:             "lightweight tag of a commit signed with a trusted key",
+			moduleIdentifier: host + "/org/lib@v1.2.0",
+			wantSigner:       sshSigner,
+		},
+		{
+			name:             "lightweight tag of an unsigned commit",
+			moduleIdentifier: host + "/org/lib@v1.3.0",
+			wantErr:          true,
+		},
+		{
+			name:             "tag signed with an untrusted key",
+			moduleIdentifier: host + "/org/lib@v2.0.0",
+			wantErr:          true,
+		},
+		{
+			name:             "pseudo-version of a commit signed with an untrusted key",
+			moduleIdentifier: host + "/org/lib",
+			wantErr:          true,
+		},
+		{
+			name:             "remote without trust policy",
+			moduleIdentifier: host + "/other/lib@v1.3.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := gf.Resolve(t.Context(), tt.moduleIdentifier)
+			if gotErr != nil {
+				if !tt.wantErr || !errors.Is(gotErr, ErrUntrustedSignature) {
+					t.Errorf("Resolve() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("Resolve() succeeded unexpectedly")
+			}
+			signer := ""
+			if signedModule, ok := got.(custodian.SignedModule); ok {
+				signer = signedModule.Signer()
+			}
+			if signer != tt.wantSigner {
+				t.Errorf("Resolve() signer = %v, want %v", signer, tt.wantSigner)
+			}
+		})
+	}
+
+	// cached modules are verified again once their key is no longer trusted
+	gf.trust[0].OpenPGPKeys = nil
+	if _, err := gf.Resolve(t.Context(), host+"/org/lib@v1.0.0"); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("Resolve() of a module signed by a revoked key = %v, want %v", err, ErrUntrustedSignature)
+	}
+}
@@ -1,23 +1,35 @@
 package resolvers
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+	goModule "golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 )
 
 const (
 	ENV_PREFIX         = "CUSTODIAN_"
+	ENV_GIT_AUTH       = ENV_PREFIX + "GIT_AUTH"
 	ENV_GIT_AUTH_MODE  = ENV_PREFIX + "GIT_AUTH_MODE"
+	ENV_GIT_SCHEME     = ENV_PREFIX + "GIT_SCHEME"
+	ENV_GIT_PORT       = ENV_PREFIX + "GIT_PORT"
+	ENV_GIT_INSTEADOF  = ENV_PREFIX + "GIT_INSTEADOF"
 	ENV_GIT_SSH_KEY    = ENV_PREFIX + "GIT_SSH_KEY"
 	ENV_GIT_AUTH_TOKEN = ENV_PREFIX + "GIT_AUTH_TOKEN"
 	ENV_GIT_USER       = ENV_PREFIX + "GIT_USER"
@@ -33,31 +45,77 @@ const (
 	GitAuthModeBasicAuth GitAuthMode = "basic-auth"
 	GitAuthModeSshKey    GitAuthMode = "ssh-key"
 	GitAuthModeSshAgent  GitAuthMode = "ssh-agent"
+
+	GitAuthModeCredentialHelper GitAuthMode = "credential-helper"
 )
 
-func getAuthMethodFromEnv() (GitAuthMode, transport.AuthMethod, error) {
-	switch GitAuthMode(os.Getenv(ENV_GIT_AUTH_MODE)) {
+const (
+	GitSchemeHttps = "https"
+	GitSchemeHttp  = "http"
+	GitSchemeSsh   = "ssh"
+)
+
+// gitCredentials holds the credentials used by the different auth modes.
+type gitCredentials struct {
+	User     string
+	Password string
+	Token    string
+	SshKey   string
+	HostKey  gitHostKeyOptions
+}
+
+func newAuthMethod(authMode GitAuthMode, host string, credentials gitCredentials) (transport.AuthMethod, error) {
+	switch authMode {
 	case GitAuthModeAuthToken:
-		return GitAuthModeAuthToken, &http.TokenAuth{
-			Token: utils.GetEnvOrEmpty(ENV_GIT_AUTH_TOKEN),
+		return &http.TokenAuth{
+			Token: credentials.Token,
 		}, nil
 	case GitAuthModeBasicAuth:
-		return GitAuthModeBasicAuth, &http.BasicAuth{
-			Username: utils.GetEnvOrEmpty(ENV_GIT_USER),
-			Password: utils.GetEnvOrEmpty(ENV_GIT_PASS),
+		return &http.BasicAuth{
+			Username: credentials.User,
+			Password: credentials.Password,
 		}, nil
 	case GitAuthModeSshKey:
-		auth, err := ssh.NewPublicKeysFromFile(
-			utils.GetEnvOrEmpty(ENV_GIT_USER), utils.GetEnvOrEmpty(ENV_GIT_SSH_KEY), utils.GetEnvOrEmpty(ENV_GIT_PASS),
-		)
-		return GitAuthModeSshKey, auth, err
+		auth, err := ssh.NewPublicKeysFromFile(credentials.User, credentials.SshKey, credentials.Password)
+		if err != nil {
+			return nil, err
+		}
+		return auth, setHostKeyCallback(auth, host, credentials.HostKey)
 	case GitAuthModeSshAgent:
 		auth, err := ssh.NewSSHAgentAuth("git")
-		return GitAuthModeSshAgent, auth, err
+		if err != nil {
+			return nil, err
+		}
+		return auth, setHostKeyCallback(auth, host, credentials.HostKey)
+	case GitAuthModeCredentialHelper:
+		return credentialHelperAuth(host)
+	case GitAuthModeNone, "":
+		return nil, nil
 	default:
-		return GitAuthModeNone, nil, nil
+		return nil, fmt.Errorf("unknown git auth mode: %s", authMode)
 	}
+}
 
+// getHostConfigFromEnv returns the configuration used by the remotes whose
+// host is not configured otherwise.
+func getHostConfigFromEnv() GitHostConfig {
+	authMode := GitAuthMode(os.Getenv(ENV_GIT_AUTH_MODE))
+	switch authMode {
+	case GitAuthModeAuthToken, GitAuthModeBasicAuth, GitAuthModeSshKey, GitAuthModeSshAgent, GitAuthModeCredentialHelper:
+	default:
+		authMode = GitAuthModeNone
+	}
+	return GitHostConfig{
+		AuthMode: authMode,
+		User:     utils.GetEnvOrEmpty(ENV_GIT_USER),
+		Password: utils.GetEnvOrEmpty(ENV_GIT_PASS),
+		Token:    utils.GetEnvOrEmpty(ENV_GIT_AUTH_TOKEN),
+		SshKey:   utils.GetEnvOrEmpty(ENV_GIT_SSH_KEY),
+
+		KnownHosts:       splitPathList(utils.GetEnvOrEmpty(ENV_GIT_KNOWN_HOSTS)),
+		HostFingerprints: splitList(utils.GetEnvOrEmpty(ENV_GIT_SSH_HOST_FINGERPRINT)),
+		InsecureHostKey:  utils.GetEnvOrEmpty(ENV_GIT_SSH_INSECURE) == "true",
+	}
 }
 
 func ParseModuleIdentifier(moduleIdentifier string) (string, string) {
@@ -68,12 +126,22 @@ func ParseModuleIdentifier(moduleIdentifier string) (string, string) {
 	return mIdData[0], ""
 }
 
-func buildRemoteURL(authMethod GitAuthMode, remoteIdentifier string) string {
-	switch authMethod {
-	case GitAuthModeSshKey, GitAuthModeSshAgent:
-		return "git@" + strings.Replace(remoteIdentifier, "/", ":", 1) // convert host_fqdn/owner/repo to git@host_fqdn:owner/repo
+func buildRemoteURL(scheme string, port int, remoteIdentifier string) string {
+	host, repoPath, _ := strings.Cut(remoteIdentifier, "/")
+	if port != 0 {
+		host = host + ":" + strconv.Itoa(port)
+	}
+	switch scheme {
+	case GitSchemeSsh:
+		if port != 0 {
+			// the scp-like syntax cannot express a port
+			return "ssh://git@" + host + "/" + repoPath
+		}
+		return "git@" + host + ":" + repoPath // convert host_fqdn/owner/repo to git@host_fqdn:owner/repo
+	case GitSchemeHttp:
+		return "http://" + host + "/" + repoPath
 	default:
-		return "https://" + remoteIdentifier // convert host_fqdn/owner/repo to https://host_fqdn/owner/repo
+		return "https://" + host + "/" + repoPath // convert host_fqdn/owner/repo to https://host_fqdn/owner/repo
 	}
 }
 
@@ -93,28 +161,95 @@ func resolveObjectToCommitHash(gitObject object.Object, repo *git.Repository) (s
 	}
 }
 
-func getCommitHashTagMap(repo *git.Repository) (map[string][]string, error) {
-	tags, err := repo.Tags()
+// resolveCommit returns the commit designated by a tag, a branch, a commit
+// hash or hash prefix, or the revision of a pseudo-version, and HEAD for an
+// empty commitIdentifier.
+func resolveCommit(repo *git.Repository, commitIdentifier string) (*object.Commit, error) {
+	revision := plumbing.Revision(plumbing.HEAD)
+	if goModule.IsPseudoVersion(commitIdentifier) {
+		// Handle pseudo-versions getting only the commit hash
+		rev, err := goModule.PseudoVersionRev(commitIdentifier)
+		if err != nil {
+			return nil, err
+		}
+		revision = plumbing.Revision(rev)
+	} else if commitIdentifier != "" {
+		revision = plumbing.Revision(commitIdentifier)
+	}
+	hash, err := repo.ResolveRevision(revision)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("commit %s not found: %w", commitIdentifier, err)
 	}
+	return repo.CommitObject(*hash)
+}
 
-	tagCommitMap := make(map[string][]string) // commit hash -> tag name
-	err = tags.ForEach(func(ref *plumbing.Reference) error {
-		obj, err := repo.Object(plumbing.AnyObject, ref.Hash())
-		if err != nil {
-			return err
+// peelTagCommits replaces the annotated tag objects of a tag to commit map by
+// the commits they point to, for remotes not listing them.
+func peelTagCommits(repo *git.Repository, tagCommits map[string]string) (map[string]string, error) {
+	peeled := make(map[string]string, len(tagCommits))
+	for tag, hash := range tagCommits {
+		peeled[tag] = hash
+		obj, err := repo.Object(plumbing.TagObject, plumbing.NewHash(hash))
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			continue
 		}
-		commitHash, err := resolveObjectToCommitHash(obj, repo)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		tagCommitMap[commitHash] = append(tagCommitMap[commitHash], ref.Name().Short())
-		return nil
-	})
+		if peeled[tag], err = resolveObjectToCommitHash(obj, repo); err != nil {
+			return nil, err
+		}
+	}
+	return peeled, nil
+}
+
+// semverTagsByCommit inverts a tag to commit map, keeping only the semver
+// tags, sorted.
+func semverTagsByCommit(tagCommits map[string]string) map[string][]string {
+	commitTags := make(map[string][]string) // commit hash -> tag names
+	for tag, commit := range tagCommits {
+		if semver.IsValid(tag) {
+			commitTags[commit] = append(commitTags[commit], tag)
+		}
+	}
+	for _, tags := range commitTags {
+		semver.Sort(tags)
+	}
+	return commitTags
+}
+
+// isFullCommitHash reports whether s is a full hexadecimal commit hash.
+func isFullCommitHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
 
+// listTagCommits returns the commit hash each tag of the remote points to,
+// peeling annotated tags, without fetching the repository.
+func listTagCommits(ctx context.Context, remote *gitRemote) (map[string]string, error) {
+	gitRemote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{remote.url},
+	})
+	refs, err := gitRemote.ListContext(ctx, &git.ListOptions{Auth: remote.method, PeelingOption: git.AppendPeeled})
 	if err != nil {
 		return nil, err
 	}
-	return tagCommitMap, nil
+
+	tagCommits := make(map[string]string)
+	peeled := make(map[string]string)
+	for _, ref := range refs {
+		if name, found := strings.CutSuffix(ref.Name().String(), "^{}"); found {
+			peeled[plumbing.ReferenceName(name).Short()] = ref.Hash().String()
+		} else if ref.Name().IsTag() {
+			tagCommits[ref.Name().Short()] = ref.Hash().String()
+		}
+	}
+	for tag, commit := range peeled {
+		tagCommits[tag] = commit
+	}
+	return tagCommits, nil
 }
@@ -0,0 +1,43 @@
+package resolvers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+)
+
+// ErrLockMismatch is returned for modules whose content is not the one
+// recorded in the lock file.
+var ErrLockMismatch = errors.New("module content does not match the lock file")
+
+// WithLockFile makes the resolver verify the modules recorded in lockFile
+// against their locked hash, and resolve locked OCI tags to their locked
+// digest.
+func WithLockFile(lockFile *modules.LockFile) Option {
+	return func(f *chainResolver) {
+		f.lockFile = lockFile
+		if ociResolver, ok := f.ociResolver.(*ociResolver); ok {
+			ociResolver.lockFile = lockFile
+		}
+	}
+}
+
+// verifyLocked checks the hash of module against its lock entry, if any, so
+// that a tampered module cache or proxy is noticed.
+func verifyLocked(lockFile *modules.LockFile, module custodian.Module) error {
+	entry, locked := lockFile.Lookup(module.Identifier())
+	if !locked {
+		return nil
+	}
+	hash, err := modzip.HashFS(module.Identifier(), module.FileSystem())
+	if err != nil {
+		return err
+	}
+	if hash != entry.Hash {
+		return fmt.Errorf("%s: %w: resolved %s, %s %s", module.Identifier(), ErrLockMismatch, hash, modules.LockFileName, entry.Hash)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+)
+
+func Test_chainResolver_Resolve_lockFile(t *testing.T) {
+	lockedHash, err := modzip.HashFS("github.com/org/lib@v1.0.0", fstest.MapFS{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := &chainResolver{
+		localResolver:  &stubResolver{},
+		remoteResolver: &stubResolver{},
+		lockFile: &modules.LockFile{Modules: []modules.LockEntry{
+			{Module: "github.com/org/lib@v1.0.0", Hash: lockedHash},
+			{Module: "github.com/org/tampered@v1.0.0", Hash: "h1:tampered"},
+		}},
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		wantErr          bool
+	}{
+		{
+			name:             "locked hash",
+			moduleIdentifier: "github.com/org/lib@v1.0.0",
+		},
+		{
+			name:             "other hash than locked",
+			moduleIdentifier: "github.com/org/tampered@v1.0.0",
+			wantErr:          true,
+		},
+		{
+			name:             "module not locked",
+			moduleIdentifier: "github.com/org/new@v1.0.0",
+		},
+		{
+			name:             "local path",
+			moduleIdentifier: "./lib",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, gotErr := resolver.Resolve(context.Background(), tt.moduleIdentifier)
+			if gotErr != nil {
+				if !tt.wantErr || !errors.Is(gotErr, ErrLockMismatch) {
+					t.Errorf("Resolve() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("Resolve() succeeded unexpectedly")
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package resolvers
+
+import (
+	"context"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/oci"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+const (
+	ociCacheDir = "oci"
+)
+
+// IsOCIIdentifier reports whether moduleIdentifier is an oci:// reference.
+func IsOCIIdentifier(moduleIdentifier string) bool {
+	return strings.HasPrefix(moduleIdentifier, oci.Scheme)
+}
+
+// ociModule is a module pulled from an OCI registry, with the manifest
+// digest its reference was resolved to.
+type ociModule struct {
+	custodian.Module
+	digest string
+}
+
+func (m *ociModule) Digest() string {
+	return m.digest
+}
+
+type ociResolver struct {
+	moduleCacheDir string
+	// lockFile pins tags to the digest they were locked to.
+	lockFile *modules.LockFile
+}
+
+func (f *ociResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	ref, err := oci.ParseReference(moduleIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	var digest string
+	if digestRef, ok := ref.(name.Digest); ok {
+		digest = digestRef.DigestStr()
+	} else if entry, locked := f.lockFile.Lookup(moduleIdentifier); locked && entry.Digest != "" {
+		digest = entry.Digest
+	} else if digest, err = oci.Digest(ctx, ref); err != nil {
+		return nil, err
+	}
+
+	// artifacts are cached by manifest digest, tags not locked are resolved
+	// every time
+	algorithm, encoded, _ := strings.Cut(digest, ":")
+	targetDir := filepath.Join(f.moduleCacheDir, ociCacheDir, algorithm, encoded)
+	if !utils.DirExists(targetDir) {
+		log.Printf("Downloading: %s (%s)", moduleIdentifier, digest)
+		if _, err := oci.Pull(ctx, ref.Context().Digest(digest), targetDir); err != nil {
+			return nil, err
+		}
+		log.Println("Module downloaded", moduleIdentifier)
+	}
+
+	module, err := modules.NewModuleFromFS(moduleIdentifier, utils.DirFS(targetDir))
+	if err != nil {
+		return nil, err
+	}
+	return &ociModule{Module: module, digest: digest}, nil
+}
+
+// ociModuleName returns the last element of the repository of an OCI
+// reference, e.g. lib for oci://registry.example.com/jsonnet/lib:v1.0.0.
+func ociModuleName(moduleIdentifier string) string {
+	ref, err := oci.ParseReference(moduleIdentifier)
+	if err != nil {
+		return ""
+	}
+	return path.Base(ref.Context().RepositoryStr())
+}
+
+// NewOCIResolver returns a resolver pulling modules from OCI registries into
+// the module cache in targetDir.
+func NewOCIResolver(targetDir string) custodian.Resolver {
+	return &ociResolver{
+		moduleCacheDir: targetDir,
+	}
+}
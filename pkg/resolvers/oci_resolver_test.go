@@ -0,0 +1,180 @@
+package resolvers
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/oci"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+// pushTestModule pushes a module with the given main.libsonnet to reference
+// and returns the manifest digest.
+func pushTestModule(t *testing.T, reference, main string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"custodian.json": `{"module": "example.com/jsonnet/lib", "require": {}}`,
+		"main.libsonnet": main,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	ref, err := oci.ParseReference(reference)
+	if err != nil {
+		t.Fatalf("ParseReference() failed: %v", err)
+	}
+	digest, err := oci.Push(context.Background(), ref, "example.com/jsonnet/lib@"+ref.Identifier(), dir)
+	if err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+	return digest
+}
+
+func Test_ociResolver_Resolve(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(server.Close)
+	repository := oci.Scheme + strings.TrimPrefix(server.URL, "http://") + "/jsonnet/lib"
+
+	v1Digest := pushTestModule(t, repository+":v1.0.0", "{ a: 1 }")
+	v2Digest := pushTestModule(t, repository+":v2.0.0", "{ a: 2 }")
+
+	resolver := NewOCIResolver(t.TempDir())
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		wantDigest       string
+		wantMain         string
+		wantErr          bool
+	}{
+		{
+			name:             "tag",
+			moduleIdentifier: repository + ":v1.0.0",
+			wantDigest:       v1Digest,
+			wantMain:         "{ a: 1 }",
+		},
+		{
+			name:             "digest",
+			moduleIdentifier: repository + "@" + v2Digest,
+			wantDigest:       v2Digest,
+			wantMain:         "{ a: 2 }",
+		},
+		{
+			name:             "unknown tag",
+			moduleIdentifier: repository + ":v3.0.0",
+			wantErr:          true,
+		},
+		{
+			name:             "invalid reference",
+			moduleIdentifier: oci.Scheme + "Invalid Reference",
+			wantErr:          true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := resolver.Resolve(context.Background(), tt.moduleIdentifier)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("Resolve() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("Resolve() succeeded unexpectedly")
+			}
+			if got.Identifier() != tt.moduleIdentifier {
+				t.Errorf("Resolve() = %v, want %v", got.Identifier(), tt.moduleIdentifier)
+			}
+			digestModule, ok := got.(custodian.DigestModule)
+			if !ok || digestModule.Digest() != tt.wantDigest {
+				t.Errorf("Resolve() digest = %v, want %v", digestModule, tt.wantDigest)
+			}
+			main, err := fs.ReadFile(got.FileSystem(), "main.libsonnet")
+			if err != nil || string(main) != tt.wantMain {
+				t.Errorf("main.libsonnet = %q, %v, want %q", main, err, tt.wantMain)
+			}
+		})
+	}
+
+	// moving a tag changes the module resolved from it
+	pushTestModule(t, repository+":v1.0.0", "{ a: 3 }")
+	got, err := resolver.Resolve(context.Background(), repository+":v1.0.0")
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if got.(custodian.DigestModule).Digest() == v1Digest {
+		t.Errorf("Resolve() returned the digest of the moved tag")
+	}
+}
+
+func Test_ociResolver_Resolve_lockFile(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(server.Close)
+	repository := oci.Scheme + strings.TrimPrefix(server.URL, "http://") + "/jsonnet/lib"
+
+	lockedDigest := pushTestModule(t, repository+":v1.0.0", "{ a: 1 }")
+	movedDigest := pushTestModule(t, repository+":v1.0.0", "{ a: 2 }")
+
+	resolver := &ociResolver{
+		moduleCacheDir: t.TempDir(),
+		lockFile: &modules.LockFile{Modules: []modules.LockEntry{
+			{Module: repository + ":v1.0.0", Hash: "h1:unchecked", Digest: lockedDigest},
+		}},
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		wantDigest       string
+		wantMain         string
+	}{
+		{
+			name:             "locked tag",
+			moduleIdentifier: repository + ":v1.0.0",
+			wantDigest:       lockedDigest,
+			wantMain:         "{ a: 1 }",
+		},
+		{
+			name:             "digest",
+			moduleIdentifier: repository + "@" + movedDigest,
+			wantDigest:       movedDigest,
+			wantMain:         "{ a: 2 }",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := resolver.Resolve(context.Background(), tt.moduleIdentifier)
+			if gotErr != nil {
+				t.Fatalf("Resolve() failed: %v", gotErr)
+			}
+			if digest := got.(custodian.DigestModule).Digest(); digest != tt.wantDigest {
+				t.Errorf("Resolve() digest = %v, want %v", digest, tt.wantDigest)
+			}
+			main, err := fs.ReadFile(got.FileSystem(), "main.libsonnet")
+			if err != nil || string(main) != tt.wantMain {
+				t.Errorf("main.libsonnet = %q, %v, want %q", main, err, tt.wantMain)
+			}
+		})
+	}
+
+	// once cached, locked tags need no registry
+	server.Close()
+	if _, err := resolver.Resolve(context.Background(), repository+":v1.0.0"); err != nil {
+		t.Errorf("Resolve() without registry failed: %v", err)
+	}
+}
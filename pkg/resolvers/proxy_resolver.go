@@ -0,0 +1,344 @@
+package resolvers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
+
+	goModule "golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// The module proxy protocol is modeled on Go's GOPROXY protocol. For a remote
+// such as github.com/org/lib, a proxy serves:
+//
+//	GET <proxy>/<remote>/@v/list            known versions, one per line
+//	GET <proxy>/<remote>/@v/<version>.info  JSON ProxyVersionInfo; the version
+//	                                        may be any query accepted by the
+//	                                        git resolver (tag, commit, ...)
+//	GET <proxy>/<remote>/@v/<version>.mod   the module file of the version
+//	GET <proxy>/<remote>/@v/<version>.zip   the module archive of the version
+//
+// Remotes and versions are escaped as in Go, replacing upper case letters by
+// '!' and their lower case. Archives use the modzip format.
+// A 404 or 410 response means the proxy does not have the module.
+
+const (
+	ENV_PROXY = ENV_PREFIX + "PROXY"
+
+	ProxyDirect = "direct"
+	ProxyOff    = "off"
+)
+
+// ErrModuleNotFound is returned by resolvers that do not know a module, so
+// the next resolver in a fallback list is tried.
+var ErrModuleNotFound = errors.New("module not found")
+
+// ProxyVersionInfo is the response of the .info endpoint.
+type ProxyVersionInfo struct {
+	Version string
+	Time    time.Time `json:",omitzero"`
+}
+
+type proxyResolver struct {
+	baseURL        string
+	client         *http.Client
+	moduleCacheDir string
+	// trust are the trust policies of the git resolver: proxies serve no
+	// signatures, so their remotes are left to it.
+	trust []GitTrustConfig
+}
+
+func (f *proxyResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	if remote := GitModuleIdentifier(moduleIdentifier).Remote(); trustFor(f.trust, remote) != nil {
+		return nil, fmt.Errorf("%s: %w: signatures are not served by module proxies", moduleIdentifier, ErrModuleNotFound)
+	}
+	resolvedIdentifier, err := f.getModule(ctx, moduleIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	rFs := utils.DirFS(f.modulePathFromIdentifier(resolvedIdentifier))
+	return modules.NewModuleFromFS(resolvedIdentifier, rFs)
+}
+
+func (f *proxyResolver) modulePathFromIdentifier(moduleIdentifier string) string {
+	return path.Join(f.moduleCacheDir, moduleIdentifier)
+}
+
+func (f *proxyResolver) getModule(ctx context.Context, moduleIdentifier string) (string, error) {
+	// module already exists in module cache
+	if utils.DirExists(f.modulePathFromIdentifier(moduleIdentifier)) {
+		return moduleIdentifier, nil
+	}
+
+	mId := GitModuleIdentifier(moduleIdentifier)
+	if mId.Branch() != "" {
+		// branches move, only the git resolver can follow them
+		return "", fmt.Errorf("%s: %w: branches are not served by module proxies", moduleIdentifier, ErrModuleNotFound)
+	}
+	if mId.Subpath() != "" {
+		return "", fmt.Errorf("%s: %w: subpaths are not served by module proxies", moduleIdentifier, ErrModuleNotFound)
+	}
+	remoteIdentifier, version := mId.Remote(), mId.Version()
+
+	if version == "" {
+		latest, err := f.latestVersion(ctx, remoteIdentifier)
+		if err != nil {
+			return "", err
+		}
+		version = latest
+	}
+
+	info := &ProxyVersionInfo{}
+	if err := f.getJSON(ctx, remoteIdentifier, version, ".info", info); err != nil {
+		return "", err
+	}
+	// the version names the module cache directory
+	if err := checkProxyVersion(version, info.Version); err != nil {
+		return "", fmt.Errorf("%s: invalid response from %s: %w", moduleIdentifier, f.baseURL, err)
+	}
+
+	moduleIdentifier = remoteIdentifier + VersionSeparator + info.Version
+	targetDir := f.modulePathFromIdentifier(moduleIdentifier)
+	if utils.DirExists(targetDir) {
+		return moduleIdentifier, nil
+	}
+
+	log.Printf("Downloading: %s from %s", moduleIdentifier, f.baseURL)
+	body, err := f.get(ctx, remoteIdentifier, info.Version, ".zip")
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	if err := modzip.Extract(body, moduleIdentifier, targetDir); err != nil {
+		return "", fmt.Errorf("invalid module zip for %s: %w", moduleIdentifier, err)
+	}
+	log.Println("Module downloaded", moduleIdentifier)
+	return moduleIdentifier, nil
+}
+
+// checkProxyVersion verifies the version a proxy resolved query to: a
+// semantic version, equal to query if query is a canonical one.
+func checkProxyVersion(query, version string) error {
+	if !semver.IsValid(version) {
+		return fmt.Errorf("version %q is not a semantic version", version)
+	}
+	if semver.IsValid(query) && semver.Canonical(query) == query && version != query {
+		return fmt.Errorf("version %q resolved to %q", query, version)
+	}
+	return nil
+}
+
+// ListVersions returns the versions of the remote listed by the proxy.
+func (f *proxyResolver) ListVersions(ctx context.Context, remoteIdentifier string) ([]string, error) {
+	body, err := f.get(ctx, remoteIdentifier, "", "list")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, version := range strings.Fields(string(data)) {
+		if semver.IsValid(version) {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+// latestVersion returns the highest release version listed by the proxy, or
+// the highest pre-release if there is no release.
+func (f *proxyResolver) latestVersion(ctx context.Context, remoteIdentifier string) (string, error) {
+	versions, err := f.ListVersions(ctx, remoteIdentifier)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", fmt.Errorf("%s: %w: no versions listed by %s", remoteIdentifier, ErrModuleNotFound, f.baseURL)
+	}
+	semver.Sort(versions)
+	latest := versions[len(versions)-1]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if semver.Prerelease(versions[i]) == "" {
+			latest = versions[i]
+			break
+		}
+	}
+	return latest, nil
+}
+
+func (f *proxyResolver) getJSON(ctx context.Context, remoteIdentifier, version, suffix string, v any) error {
+	body, err := f.get(ctx, remoteIdentifier, version, suffix)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(v)
+}
+
+// get requests <remote>/@v/<version><suffix>, or <remote>/@v/list when the
+// suffix is "list".
+func (f *proxyResolver) get(ctx context.Context, remoteIdentifier, version, suffix string) (io.ReadCloser, error) {
+	escapedRemote, err := goModule.EscapePath(remoteIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	file := suffix
+	if suffix != "list" {
+		escapedVersion, err := goModule.EscapeVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		file = escapedVersion + suffix
+	}
+	requestURL := strings.TrimSuffix(f.baseURL, "/") + "/" + escapedRemote + "/@v/" + file
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound, http.StatusGone:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w: %s", requestURL, ErrModuleNotFound, resp.Status)
+	default:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s: %s", requestURL, resp.Status, strings.TrimSpace(string(message)))
+	}
+}
+
+// fallbackResolver tries its resolvers in order, as configured by
+// CUSTODIAN_PROXY. After an entry followed by ',' the next one is only tried
+// if the module was not found; after '|' it is tried on any error.
+type fallbackResolver struct {
+	resolvers  []custodian.Resolver
+	onAnyError []bool
+}
+
+func (f *fallbackResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	var errs []error
+	for i, resolver := range f.resolvers {
+		module, err := resolver.Resolve(ctx, moduleIdentifier)
+		if err == nil {
+			return module, nil
+		}
+		errs = append(errs, err)
+		if !f.onAnyError[i] && !errors.Is(err, ErrModuleNotFound) {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// ListVersions lists the versions with the first resolver able to, following
+// the same fallback rules as Resolve.
+func (f *fallbackResolver) ListVersions(ctx context.Context, remoteIdentifier string) ([]string, error) {
+	var errs []error
+	for i, resolver := range f.resolvers {
+		lister, ok := resolver.(custodian.VersionLister)
+		if !ok {
+			continue
+		}
+		versions, err := lister.ListVersions(ctx, remoteIdentifier)
+		if err == nil {
+			return versions, nil
+		}
+		errs = append(errs, err)
+		if !f.onAnyError[i] && !errors.Is(err, ErrModuleNotFound) {
+			break
+		}
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("%s: %w: versions cannot be listed", remoteIdentifier, ErrModuleNotFound)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// offResolver refuses every module, for CUSTODIAN_PROXY=off.
+type offResolver struct{}
+
+func (f *offResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	return nil, fmt.Errorf("%s: module lookup disabled by %s=%s", moduleIdentifier, ENV_PROXY, ProxyOff)
+}
+
+// newRemoteResolver returns the resolver for non-local modules described by
+// proxySettings, a list of proxy URLs, "direct" for the git resolver and
+// "off", separated by ',' or '|'. An empty list means "direct". Proxies
+// leave the remotes with a trust policy of the git resolver to it.
+func newRemoteResolver(proxySettings string, directResolver custodian.Resolver, targetDir string) (custodian.Resolver, error) {
+	if proxySettings == "" || proxySettings == ProxyDirect {
+		return directResolver, nil
+	}
+
+	var trust []GitTrustConfig
+	if git, ok := directResolver.(*gitResolver); ok {
+		trust = git.trust
+	}
+	resolver := &fallbackResolver{}
+	for proxySettings != "" {
+		entry, onAnyError := proxySettings, false
+		if i := strings.IndexAny(proxySettings, ",|"); i >= 0 {
+			entry, onAnyError, proxySettings = proxySettings[:i], proxySettings[i] == '|', proxySettings[i+1:]
+		} else {
+			proxySettings = ""
+		}
+
+		switch entry = strings.TrimSpace(entry); entry {
+		case "":
+			continue
+		case ProxyDirect:
+			resolver.resolvers = append(resolver.resolvers, directResolver)
+		case ProxyOff:
+			resolver.resolvers = append(resolver.resolvers, &offResolver{})
+		default:
+			if !strings.HasPrefix(entry, "https://") && !strings.HasPrefix(entry, "http://") {
+				return nil, fmt.Errorf("invalid %s entry %q: must be a URL, %q or %q", ENV_PROXY, entry, ProxyDirect, ProxyOff)
+			}
+			resolver.resolvers = append(resolver.resolvers, &proxyResolver{
+				baseURL:        entry,
+				client:         http.DefaultClient,
+				moduleCacheDir: targetDir,
+				trust:          trust,
+			})
+		}
+		resolver.onAnyError = append(resolver.onAnyError, onAnyError)
+	}
+	if len(resolver.resolvers) == 0 {
+		return directResolver, nil
+	}
+	return resolver, nil
+}
+
+// NewProxyResolver returns a resolver downloading modules from the module
+// proxy at baseURL into the module cache in targetDir.
+func NewProxyResolver(baseURL string, targetDir string) custodian.Resolver {
+	return &proxyResolver{
+		baseURL:        baseURL,
+		client:         http.DefaultClient,
+		moduleCacheDir: targetDir,
+	}
+}
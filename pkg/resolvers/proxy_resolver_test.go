@@ -0,0 +1,225 @@
+package resolvers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+)
+
+// newTestProxy serves github.com/org/!lib (github.com/org/Lib) at v1.0.0 and
+// v1.1.0-rc.1, whose archive is invalid, and resolves the commit 0123456789ab
+// to v1.0.0. It resolves the tag escape to a path, and v2.0.0 to v1.0.0.
+func newTestProxy(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /github.com/org/!lib/@v/list", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v1.0.0\nv1.1.0-rc.1\n"))
+	})
+	mux.HandleFunc("GET /github.com/org/!lib/@v/{file}", func(w http.ResponseWriter, r *http.Request) {
+		file := r.PathValue("file")
+		switch {
+		case file == "v1.0.0.info", file == "0123456789ab.info":
+			json.NewEncoder(w).Encode(ProxyVersionInfo{Version: "v1.0.0", Time: time.Unix(0, 0)})
+		case file == "v1.1.0-rc.1.info":
+			json.NewEncoder(w).Encode(ProxyVersionInfo{Version: "v1.1.0-rc.1", Time: time.Unix(0, 0)})
+		case file == "escape.info":
+			json.NewEncoder(w).Encode(ProxyVersionInfo{Version: "../../../../tmp"})
+		case file == "v2.0.0.info":
+			json.NewEncoder(w).Encode(ProxyVersionInfo{Version: "v1.0.0"})
+		case file == "v1.0.0.zip":
+			zw := zip.NewWriter(w)
+			for name, content := range map[string]string{
+				"custodian.json":      `{"module": "github.com/org/Lib", "require": {}}`,
+				"main.libsonnet":      "{ a: 1 }",
+				"sub/other.libsonnet": "{ b: 2 }",
+			} {
+				fw, _ := zw.Create("github.com/org/Lib@v1.0.0/" + name)
+				fw.Write([]byte(content))
+			}
+			zw.Close()
+		case strings.HasSuffix(file, ".zip"):
+			zw := zip.NewWriter(w)
+			fw, _ := zw.Create("github.com/org/Lib@v1.1.0-rc.1/../escape.libsonnet")
+			fw.Write([]byte("{}"))
+			zw.Close()
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test_proxyResolver_Resolve(t *testing.T) {
+	server := newTestProxy(t)
+	resolver := NewProxyResolver(server.URL, t.TempDir())
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		want             string
+		wantNotFound     bool
+		wantErr          bool
+	}{
+		{
+			name:             "exact version",
+			moduleIdentifier: "github.com/org/Lib@v1.0.0",
+			want:             "github.com/org/Lib@v1.0.0",
+		},
+		{
+			name:             "commit hash resolved by the proxy",
+			moduleIdentifier: "github.com/org/Lib@0123456789ab",
+			want:             "github.com/org/Lib@v1.0.0",
+		},
+		{
+			name:             "latest release",
+			moduleIdentifier: "github.com/org/Lib",
+			want:             "github.com/org/Lib@v1.0.0",
+		},
+		{
+			name:             "unknown module",
+			moduleIdentifier: "github.com/org/unknown@v1.0.0",
+			wantNotFound:     true,
+			wantErr:          true,
+		},
+		{
+			name:             "branch",
+			moduleIdentifier: "github.com/org/Lib/main",
+			wantNotFound:     true,
+			wantErr:          true,
+		},
+		{
+			name:             "version leaving the module cache",
+			moduleIdentifier: "github.com/org/Lib@escape",
+			wantErr:          true,
+		},
+		{
+			name:             "other version than requested",
+			moduleIdentifier: "github.com/org/Lib@v2.0.0",
+			wantErr:          true,
+		},
+		{
+			name:             "invalid archive",
+			moduleIdentifier: "github.com/org/Lib@v1.1.0-rc.1",
+			wantErr:          true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := resolver.Resolve(context.Background(), tt.moduleIdentifier)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("Resolve() failed: %v", gotErr)
+				}
+				if errors.Is(gotErr, ErrModuleNotFound) != tt.wantNotFound {
+					t.Errorf("Resolve() error = %v, want not found %v", gotErr, tt.wantNotFound)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("Resolve() succeeded unexpectedly")
+			}
+			if got.Identifier() != tt.want {
+				t.Errorf("Resolve() = %v, want %v", got.Identifier(), tt.want)
+			}
+			if _, err := fs.Stat(got.FileSystem(), "sub/other.libsonnet"); err != nil {
+				t.Errorf("Resolve() module is missing files: %v", err)
+			}
+		})
+	}
+
+	// remotes with a trust policy are left to the git resolver, even when
+	// the proxy serves them
+	trusting := &proxyResolver{
+		baseURL:        server.URL,
+		client:         http.DefaultClient,
+		moduleCacheDir: t.TempDir(),
+		trust:          []GitTrustConfig{{Remote: "github.com/org/*"}},
+	}
+	if _, err := trusting.Resolve(context.Background(), "github.com/org/Lib@v1.0.0"); !errors.Is(err, ErrModuleNotFound) {
+		t.Errorf("Resolve() of a trusted remote error = %v, want %v", err, ErrModuleNotFound)
+	}
+}
+
+type recordingResolver struct {
+	name     string
+	err      error
+	resolved *[]string
+}
+
+func (r *recordingResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	*r.resolved = append(*r.resolved, r.name)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return (&stubResolver{}).Resolve(ctx, moduleIdentifier)
+}
+
+func Test_newRemoteResolver(t *testing.T) {
+	server := newTestProxy(t)
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		proxySettings string
+		gitErr        error
+		want          []string
+		wantErr       bool
+	}{
+		{
+			name:          "direct only",
+			proxySettings: "direct",
+			want:          []string{"git"},
+		},
+		{
+			name:          "fall back to direct when the proxy does not have the module",
+			proxySettings: server.URL + ",direct",
+			want:          []string{"git"},
+		},
+		{
+			name:          "do not fall back on other errors",
+			proxySettings: "http://127.0.0.1:0,direct",
+			want:          []string{},
+			wantErr:       true,
+		},
+		{
+			name:          "fall back on any error",
+			proxySettings: "http://127.0.0.1:0|direct",
+			want:          []string{"git"},
+		},
+		{
+			name:          "off",
+			proxySettings: server.URL + ",off",
+			want:          []string{},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved := []string{}
+			gitResolver := &recordingResolver{name: "git", err: tt.gitErr, resolved: &resolved}
+			resolver, err := newRemoteResolver(tt.proxySettings, gitResolver, t.TempDir())
+			if err != nil {
+				t.Fatalf("newRemoteResolver() failed: %v", err)
+			}
+			_, gotErr := resolver.Resolve(context.Background(), "github.com/org/unknown@v1.0.0")
+			if (gotErr != nil) != tt.wantErr {
+				t.Errorf("Resolve() error = %v, wantErr %v", gotErr, tt.wantErr)
+			}
+			if strings.Join(resolved, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Resolve() tried %v, want %v", resolved, tt.want)
+			}
+		})
+	}
+}
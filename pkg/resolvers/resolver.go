@@ -2,22 +2,35 @@ package resolvers
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/oci"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/policy"
 	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
 )
 
-type localResolver struct{}
+// localResolver resolves local paths relative to baseDir, or to the current
+// directory if there is none.
+type localResolver struct {
+	baseDir string
+}
 
 func (f *localResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
-	fPath, err := filepath.Abs(moduleIdentifier)
+	fPath := moduleIdentifier
+	if !filepath.IsAbs(fPath) {
+		fPath = filepath.Join(f.baseDir, fPath)
+	}
+	fPath, err := filepath.Abs(fPath)
 	if err != nil {
 		return nil, err
 	}
-	rFs := os.DirFS(fPath)
+	rFs := utils.DirFS(fPath)
 	module, err := modules.NewModuleFromFS(moduleIdentifier, rFs)
 	if err != nil {
 		return nil, err
@@ -26,26 +39,192 @@ func (f *localResolver) Resolve(ctx context.Context, moduleIdentifier string) (c
 }
 
 type chainResolver struct {
-	localResolver custodian.Resolver
-	gitResolver   custodian.Resolver
+	localResolver   custodian.Resolver
+	gitResolver     custodian.Resolver
+	tarballResolver custodian.Resolver
+	ociResolver     custodian.Resolver
+	remoteResolver  custodian.Resolver
+	workspace       *modules.Workspace
+	policy          *policy.Policy
+	lockFile        *modules.LockFile
+	vendorDir       string
+	baseDir         string
 }
 
+// Resolve resolves moduleIdentifier, refusing the modules forbidden by the
+// policy: their remote before fetching them, and their resolved version
+// once known. Locked modules must match their locked hash.
 func (f *chainResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
-	if utils.IsLocalPath(moduleIdentifier) {
+	remote, _, checked := PolicyTarget(moduleIdentifier)
+	if checked {
+		if err := f.policy.CheckRemote(remote); err != nil {
+			return nil, err
+		}
+	}
+	module, err := f.resolve(ctx, moduleIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	// modules replaced by the workspace are local
+	if remote, version, checked := PolicyTarget(module.Identifier()); checked {
+		if err := f.policy.Check(remote, version); err != nil {
+			return nil, err
+		}
+	}
+	if err := verifyLocked(f.lockFile, module); err != nil {
+		return nil, err
+	}
+	return module, nil
+}
+
+func (f *chainResolver) resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	localGit := IsLocalGitIdentifier(moduleIdentifier)
+	if utils.IsLocalPath(moduleIdentifier) && !localGit {
 		return f.localResolver.Resolve(ctx, moduleIdentifier)
-	} else {
+	}
+	if !localGit && !IsTarballIdentifier(moduleIdentifier) && !IsOCIIdentifier(moduleIdentifier) {
+		// modules used by the workspace replace any version of their
+		// remote, subpaths being named after their directory in the remote
+		mId := GitModuleIdentifier(moduleIdentifier)
+		remote := mId.Remote()
+		if subpath := mId.Subpath(); subpath != "" {
+			remote = path.Join(remote, subpath)
+		}
+		if moduleDir, exists := f.workspace.Lookup(remote); exists {
+			return f.localResolver.Resolve(ctx, moduleDir)
+		}
+	}
+	if module, vendored, err := f.resolveVendored(moduleIdentifier); vendored {
+		return module, err
+	}
+	switch {
+	case localGit:
+		// proxies cannot serve repositories on disk
+		if f.baseDir != "" && utils.IsLocalPath(moduleIdentifier) && !filepath.IsAbs(moduleIdentifier) {
+			moduleIdentifier = filepath.ToSlash(filepath.Join(f.baseDir, moduleIdentifier))
+		}
 		return f.gitResolver.Resolve(ctx, moduleIdentifier)
+	case IsTarballIdentifier(moduleIdentifier):
+		return f.tarballResolver.Resolve(ctx, moduleIdentifier)
+	case IsOCIIdentifier(moduleIdentifier):
+		return f.ociResolver.Resolve(ctx, moduleIdentifier)
+	}
+	return f.remoteResolver.Resolve(ctx, moduleIdentifier)
+}
+
+// ListVersions lists the versions of a remote when the remote resolver
+// supports it.
+func (f *chainResolver) ListVersions(ctx context.Context, remoteIdentifier string) ([]string, error) {
+	lister, ok := f.remoteResolver.(custodian.VersionLister)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w: versions cannot be listed", remoteIdentifier, ErrModuleNotFound)
+	}
+	return lister.ListVersions(ctx, remoteIdentifier)
+}
+
+// ModuleName returns the default local name of a module in the require
+// section of custodian.json.
+func ModuleName(moduleIdentifier string) string {
+	switch {
+	case IsTarballIdentifier(moduleIdentifier):
+		return TarballModuleIdentifier(moduleIdentifier).Name()
+	case IsOCIIdentifier(moduleIdentifier):
+		return ociModuleName(moduleIdentifier)
+	}
+	if subpath := GitModuleIdentifier(moduleIdentifier).Subpath(); subpath != "" {
+		return path.Base(subpath)
+	}
+	return GitModuleIdentifier(moduleIdentifier).Repo()
+}
+
+// PolicyTarget returns the remote and version policies are checked against:
+// the remote of git modules, the URL of tarballs without its scheme and
+// checksum, and the repository of OCI references. It returns false for
+// modules on disk, which policies do not restrict.
+func PolicyTarget(moduleIdentifier string) (remote, version string, ok bool) {
+	switch {
+	case IsLocalGitIdentifier(moduleIdentifier), utils.IsLocalPath(moduleIdentifier):
+		return "", "", false
+	case IsTarballIdentifier(moduleIdentifier):
+		url := TarballModuleIdentifier(moduleIdentifier).URL()
+		_, remote, _ = strings.Cut(url, "://")
+		return remote, "", true
+	case IsOCIIdentifier(moduleIdentifier):
+		ref, err := oci.ParseReference(moduleIdentifier)
+		if err != nil {
+			return strings.TrimPrefix(moduleIdentifier, oci.Scheme), "", true
+		}
+		return ref.Context().Name(), ref.Identifier(), true
+	}
+	mId := GitModuleIdentifier(moduleIdentifier)
+	return mId.Remote(), mId.Version(), true
+}
+
+// BranchTip returns the unversioned identifier of a git module tracking a
+// branch, which resolves to the current tip of the branch, and false for
+// modules tracking no branch.
+func BranchTip(moduleIdentifier string) (string, bool) {
+	if IsTarballIdentifier(moduleIdentifier) || IsOCIIdentifier(moduleIdentifier) || utils.IsLocalPath(moduleIdentifier) {
+		return "", false
+	}
+	mId := GitModuleIdentifier(moduleIdentifier)
+	if mId.Branch() == "" {
+		return "", false
+	}
+	tip := mId.Remote() + "/" + mId.Branch()
+	if subpath := mId.Subpath(); subpath != "" {
+		tip += SubpathSeparator + subpath
+	}
+	return tip, true
+}
+
+// Option configures the resolver returned by NewResolver.
+type Option func(*chainResolver)
+
+// WithWorkspace makes the resolver substitute the workspace module
+// directories for any matching remote.
+func WithWorkspace(workspace *modules.Workspace) Option {
+	return func(f *chainResolver) {
+		f.workspace = workspace
+	}
+}
+
+// WithBaseDir makes the resolver resolve local paths relative to dir, the
+// directory of the root module, rather than the current directory.
+func WithBaseDir(dir string) Option {
+	return func(f *chainResolver) {
+		f.baseDir = dir
+		f.localResolver = &localResolver{baseDir: dir}
 	}
 }
 
-func NewResolver(targetDir string) (custodian.Resolver, error) {
+func NewResolver(targetDir string, opts ...Option) (custodian.Resolver, error) {
 	gitResolver, err := NewGitResolver(targetDir)
 	if err != nil {
 		return nil, err
 	}
-
-	return &chainResolver{
-		localResolver: &localResolver{},
-		gitResolver:   gitResolver,
-	}, nil
+	remoteResolver, err := newRemoteResolver(os.Getenv(ENV_PROXY), gitResolver, targetDir)
+	if err != nil {
+		return nil, err
+	}
+	remoteResolver, err = newSumdbResolver(os.Getenv(ENV_SUMDB), remoteResolver)
+	if err != nil {
+		return nil, err
+	}
+	resolver := &chainResolver{
+		localResolver:   &localResolver{},
+		gitResolver:     gitResolver,
+		tarballResolver: NewTarballResolver(targetDir),
+		ociResolver:     NewOCIResolver(targetDir),
+		remoteResolver:  remoteResolver,
+	}
+	for _, opt := range opts {
+		opt(resolver)
+	}
+	// the policy of the root module, in the current directory without
+	// WithBaseDir
+	if resolver.policy, err = policy.Load(resolver.baseDir); err != nil {
+		return nil, err
+	}
+	return resolver, nil
 }
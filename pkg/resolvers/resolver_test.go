@@ -0,0 +1,264 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/policy"
+)
+
+type stubResolver struct{}
+
+func (s *stubResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	return modules.NewModuleFromFS(moduleIdentifier, fstest.MapFS{})
+}
+
+func Test_chainResolver_Resolve(t *testing.T) {
+	workspaceDir := t.TempDir()
+	resolver := &chainResolver{
+		localResolver:   &stubResolver{},
+		gitResolver:     &stubResolver{},
+		tarballResolver: &stubResolver{},
+		remoteResolver:  &stubResolver{},
+		workspace: &modules.Workspace{
+			Modules: map[string]string{"github.com/org/lib": workspaceDir},
+		},
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		want             string
+	}{
+		{
+			name:             "remote replaced by workspace module",
+			moduleIdentifier: "github.com/org/lib@v1.2.0",
+			want:             workspaceDir,
+		},
+		{
+			name:             "remote branch replaced by workspace module",
+			moduleIdentifier: "github.com/org/lib/main",
+			want:             workspaceDir,
+		},
+		{
+			name:             "remote not in workspace",
+			moduleIdentifier: "github.com/org/other@v1.0.0",
+			want:             "github.com/org/other@v1.0.0",
+		},
+		{
+			name:             "tarball",
+			moduleIdentifier: "https://example.com/lib-1.2.0.tar.gz#sha256=00",
+			want:             "https://example.com/lib-1.2.0.tar.gz#sha256=00",
+		},
+		{
+			name:             "git repository on disk",
+			moduleIdentifier: "../lib@v1.2.0",
+			want:             "../lib@v1.2.0",
+		},
+		{
+			name:             "local path",
+			moduleIdentifier: "./lib",
+			want:             "./lib",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := resolver.Resolve(context.Background(), tt.moduleIdentifier)
+			if gotErr != nil {
+				t.Fatalf("Resolve() failed: %v", gotErr)
+			}
+			if got.Identifier() != tt.want {
+				t.Errorf("Resolve() = %v, want %v", got.Identifier(), tt.want)
+			}
+		})
+	}
+}
+
+func TestBranchTip(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		want             string
+		wantTracking     bool
+	}{
+		{
+			name:             "branch pinned to a pseudo-version",
+			moduleIdentifier: "github.com/org/lib/main@v0.0.0-20250101100000-0123456789ab",
+			want:             "github.com/org/lib/main",
+			wantTracking:     true,
+		},
+		{
+			name:             "branch with subpath",
+			moduleIdentifier: "github.com/org/lib/main//common@v1.0.0",
+			want:             "github.com/org/lib/main//common",
+			wantTracking:     true,
+		},
+		{
+			name:             "tag",
+			moduleIdentifier: "github.com/org/lib@v1.0.0",
+		},
+		{
+			name:             "subpath without branch",
+			moduleIdentifier: "github.com/org/lib//common@v1.0.0",
+		},
+		{
+			name:             "tarball",
+			moduleIdentifier: "https://example.com/org/lib/lib.tar.gz#sha256=00",
+		},
+		{
+			name:             "local repository",
+			moduleIdentifier: "../org/lib/main@v1.0.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, tracking := BranchTip(tt.moduleIdentifier)
+			if got != tt.want || tracking != tt.wantTracking {
+				t.Errorf("BranchTip() = %v, %v, want %v, %v", got, tracking, tt.want, tt.wantTracking)
+			}
+		})
+	}
+}
+
+func Test_chainResolver_Resolve_policy(t *testing.T) {
+	resolver := &chainResolver{
+		localResolver:   &stubResolver{},
+		gitResolver:     &stubResolver{},
+		tarballResolver: &stubResolver{},
+		ociResolver:     &stubResolver{},
+		remoteResolver:  &stubResolver{},
+		workspace: &modules.Workspace{
+			Modules: map[string]string{"github.com/forbidden/lib": t.TempDir()},
+		},
+		policy: &policy.Policy{
+			Allow: []policy.Rule{
+				{Remote: "github.com/org/*"},
+				{Remote: "github.com/forbidden/*"},
+				{Remote: "example.com/*"},
+			},
+			Deny: []policy.Rule{
+				{Remote: "github.com/org/*", Pseudo: true, Reason: "release tags only"},
+				{Remote: "github.com/org/legacy"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		wantErr          bool
+	}{
+		{
+			name:             "allowed remote and version",
+			moduleIdentifier: "github.com/org/lib@v1.0.0",
+		},
+		{
+			name:             "pseudo-version denied",
+			moduleIdentifier: "github.com/org/lib/main@v0.0.0-20250101100000-0123456789ab",
+			wantErr:          true,
+		},
+		{
+			name:             "denied remote",
+			moduleIdentifier: "github.com/org/legacy@v1.0.0",
+			wantErr:          true,
+		},
+		{
+			name:             "remote not allowed",
+			moduleIdentifier: "github.com/other/lib@v1.0.0",
+			wantErr:          true,
+		},
+		{
+			name:             "allowed tarball host",
+			moduleIdentifier: "https://example.com/lib-1.2.0.tar.gz#sha256=00",
+		},
+		{
+			name:             "OCI registry not allowed",
+			moduleIdentifier: "oci://registry.example.com/jsonnet/lib:v1.0.0",
+			wantErr:          true,
+		},
+		{
+			name:             "workspace module",
+			moduleIdentifier: "github.com/forbidden/lib@v0.0.0-20250101100000-0123456789ab",
+		},
+		{
+			name:             "local path",
+			moduleIdentifier: "./lib",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, gotErr := resolver.Resolve(context.Background(), tt.moduleIdentifier)
+			if gotErr != nil {
+				if !tt.wantErr || !errors.Is(gotErr, policy.ErrPolicyViolation) {
+					t.Errorf("Resolve() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("Resolve() succeeded unexpectedly")
+			}
+		})
+	}
+}
+
+func Test_localResolver_Resolve_baseDir(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(baseDir, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, "lib", "main.libsonnet"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	resolver := &localResolver{baseDir: baseDir}
+
+	got, err := resolver.Resolve(context.Background(), "./lib")
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if got.Identifier() != "./lib" {
+		t.Errorf("Resolve() = %v, want ./lib", got.Identifier())
+	}
+	if _, err := fs.Stat(got.FileSystem(), "main.libsonnet"); err != nil {
+		t.Errorf("Resolve() did not resolve relative to the base directory: %v", err)
+	}
+	// absolute paths, such as workspace modules, ignore the base directory
+	got, err = resolver.Resolve(context.Background(), filepath.Join(baseDir, "lib"))
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if _, err := fs.Stat(got.FileSystem(), "main.libsonnet"); err != nil {
+		t.Errorf("Resolve() of an absolute path: %v", err)
+	}
+}
+
+func TestNewResolver_policy(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(rootDir, policy.PolicyFileName), []byte(`{"deny": [{"remote": "github.com/org/*"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// run from a directory of the root module without policy file
+	subDir := filepath.Join(rootDir, "sub")
+	if err := os.Mkdir(subDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(subDir)
+	t.Setenv(policy.ENV_POLICY, "")
+
+	resolver, err := NewResolver(t.TempDir(), WithBaseDir(rootDir))
+	if err != nil {
+		t.Fatalf("NewResolver() failed: %v", err)
+	}
+	if _, err := resolver.Resolve(context.Background(), "github.com/org/lib@v1.0.0"); !errors.Is(err, policy.ErrPolicyViolation) {
+		t.Errorf("Resolve() error = %v, want %v", err, policy.ErrPolicyViolation)
+	}
+}
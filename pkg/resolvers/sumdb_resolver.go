@@ -0,0 +1,74 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/sumdb"
+)
+
+const (
+	// ENV_SUMDB names the checksum database modules are checked against, as
+	// "<verifier key> <url>", or "off".
+	ENV_SUMDB = ENV_PREFIX + "SUMDB"
+
+	SumdbOff = "off"
+)
+
+// sumdbResolver refuses the modules of the next resolver whose hash is not
+// the one recorded by the checksum database.
+type sumdbResolver struct {
+	next   custodian.Resolver
+	client *sumdb.Client
+}
+
+func (f *sumdbResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	module, err := f.next.Resolve(ctx, moduleIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := modzip.HashFS(module.Identifier(), module.FileSystem())
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := f.client.Lookup(ctx, module.Identifier())
+	if err != nil {
+		return nil, err
+	}
+	if hash != recorded {
+		return nil, fmt.Errorf("%s: %w: downloaded %s, checksum database %s", module.Identifier(), sumdb.ErrChecksumMismatch, hash, recorded)
+	}
+	return module, nil
+}
+
+// ListVersions lists the versions of the next resolver, which are not
+// recorded by the checksum database.
+func (f *sumdbResolver) ListVersions(ctx context.Context, remoteIdentifier string) ([]string, error) {
+	lister, ok := f.next.(custodian.VersionLister)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w: versions cannot be listed", remoteIdentifier, ErrModuleNotFound)
+	}
+	return lister.ListVersions(ctx, remoteIdentifier)
+}
+
+// newSumdbResolver wraps next with the checksum database of sumdbConfig, the
+// value of CUSTODIAN_SUMDB. It returns next as is if the checksum database is
+// disabled.
+func newSumdbResolver(sumdbConfig string, next custodian.Resolver) (custodian.Resolver, error) {
+	sumdbConfig = strings.TrimSpace(sumdbConfig)
+	if sumdbConfig == "" || sumdbConfig == SumdbOff {
+		return next, nil
+	}
+	fields := strings.Fields(sumdbConfig)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid %s %q: must be \"<verifier key> <url>\" or %q", ENV_SUMDB, sumdbConfig, SumdbOff)
+	}
+	client, err := sumdb.NewClient(fields[1], fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ENV_SUMDB, err)
+	}
+	return &sumdbResolver{next: next, client: client}, nil
+}
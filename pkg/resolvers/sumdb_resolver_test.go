@@ -0,0 +1,134 @@
+package resolvers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/sumdb"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// contentResolver resolves modules to a single main.libsonnet file.
+type contentResolver map[string]string
+
+func (c contentResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	content, exists := c[moduleIdentifier]
+	if !exists {
+		return nil, fmt.Errorf("%s: %w", moduleIdentifier, ErrModuleNotFound)
+	}
+	return modules.NewModuleFromFS(moduleIdentifier, fstest.MapFS{"main.libsonnet": {Data: []byte(content)}})
+}
+
+func Test_sumdbResolver_Resolve(t *testing.T) {
+	// clients keep the tree heads they verified in the user cache
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	skey, vkey, err := note.GenerateKey(rand.Reader, "sumdb.example.com")
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %v", err)
+	}
+	signer, err := note.NewSigner(skey)
+	if err != nil {
+		t.Fatalf("NewSigner() failed: %v", err)
+	}
+	checksumLog, err := sumdb.OpenLog(t.TempDir(), signer)
+	if err != nil {
+		t.Fatalf("OpenLog() failed: %v", err)
+	}
+	t.Cleanup(func() { checksumLog.Close() })
+	server := httptest.NewServer(sumdb.NewHandler(checksumLog, contentResolver{
+		"github.com/org/lib@v1.0.0":   "{ v: 1 }",
+		"github.com/org/moved@v1.0.0": "{ v: 1 }",
+	}))
+	t.Cleanup(server.Close)
+
+	resolver, err := newSumdbResolver(vkey+" "+server.URL, contentResolver{
+		"github.com/org/lib@v1.0.0":   "{ v: 1 }",
+		"github.com/org/moved@v1.0.0": "{ v: 2 }",
+		"github.com/org/new@v1.0.0":   "{ v: 1 }",
+	})
+	if err != nil {
+		t.Fatalf("newSumdbResolver() failed: %v", err)
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		wantErr          error
+	}{
+		{
+			name:             "hash matches the log",
+			moduleIdentifier: "github.com/org/lib@v1.0.0",
+		},
+		{
+			name:             "hash differs from the log",
+			moduleIdentifier: "github.com/org/moved@v1.0.0",
+			wantErr:          sumdb.ErrChecksumMismatch,
+		},
+		{
+			name:             "module unknown to the checksum database",
+			moduleIdentifier: "github.com/org/new@v1.0.0",
+			wantErr:          sumdb.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, gotErr := resolver.Resolve(context.Background(), tt.moduleIdentifier)
+			if tt.wantErr == nil && gotErr != nil {
+				t.Errorf("Resolve() failed: %v", gotErr)
+			}
+			if tt.wantErr != nil && !errors.Is(gotErr, tt.wantErr) {
+				t.Errorf("Resolve() error = %v, want %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_newSumdbResolver(t *testing.T) {
+	// clients keep the tree heads they verified in the user cache
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	_, vkey, err := note.GenerateKey(rand.Reader, "sumdb.example.com")
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %v", err)
+	}
+	next := &stubResolver{}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		sumdbConfig string
+		wantNext    bool
+		wantErr     bool
+	}{
+		{name: "unset", sumdbConfig: "", wantNext: true},
+		{name: "off", sumdbConfig: "off", wantNext: true},
+		{name: "key and url", sumdbConfig: vkey + " https://sumdb.example.com"},
+		{name: "missing url", sumdbConfig: vkey, wantErr: true},
+		{name: "invalid key", sumdbConfig: "sumdb.example.com https://sumdb.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := newSumdbResolver(tt.sumdbConfig, next)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("newSumdbResolver() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("newSumdbResolver() succeeded unexpectedly")
+			}
+			if (got == custodian.Resolver(next)) != tt.wantNext {
+				t.Errorf("newSumdbResolver() = %T, wantNext %v", got, tt.wantNext)
+			}
+		})
+	}
+}
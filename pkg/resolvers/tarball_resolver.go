@@ -0,0 +1,297 @@
+package resolvers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
+)
+
+// TarballModuleIdentifier identifies a module published as a release
+// tarball by its URL and the mandatory SHA-256 of the tarball
+// e.g. https://example.com/lib-1.2.3.tar.gz#sha256=<hex>
+type TarballModuleIdentifier string
+
+const (
+	TarballChecksumSeparator = "#sha256="
+	tarballCacheDir          = "tarball"
+)
+
+var tarballVersionSuffix = regexp.MustCompile(`-v?[0-9]+(\.[0-9]+)*([-+.].*)?$`)
+
+// IsTarballIdentifier reports whether moduleIdentifier is an http(s) URL.
+func IsTarballIdentifier(moduleIdentifier string) bool {
+	return strings.HasPrefix(moduleIdentifier, "https://") || strings.HasPrefix(moduleIdentifier, "http://")
+}
+
+func (m TarballModuleIdentifier) URL() string {
+	url, _, _ := strings.Cut(string(m), "#")
+	return url
+}
+
+// SHA256 returns the lower case hex checksum, or "" if there is none.
+func (m TarballModuleIdentifier) SHA256() string {
+	_, checksum, found := strings.Cut(string(m), TarballChecksumSeparator)
+	if !found {
+		return ""
+	}
+	return strings.ToLower(checksum)
+}
+
+// Name returns the file name of the tarball without its extension and
+// version, e.g. lib for https://example.com/lib-1.2.3.tar.gz.
+func (m TarballModuleIdentifier) Name() string {
+	urlPath, _, _ := strings.Cut(m.URL(), "?")
+	name := path.Base(urlPath)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar"} {
+		if trimmed, found := strings.CutSuffix(name, ext); found {
+			name = trimmed
+			break
+		}
+	}
+	if trimmed := tarballVersionSuffix.ReplaceAllString(name, ""); trimmed != "" {
+		name = trimmed
+	}
+	return name
+}
+
+type tarballResolver struct {
+	client         *http.Client
+	moduleCacheDir string
+}
+
+func (f *tarballResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	mId := TarballModuleIdentifier(moduleIdentifier)
+	checksum := mId.SHA256()
+	if checksum == "" {
+		return nil, fmt.Errorf("%s: tarball modules need a checksum, e.g. %s%s<hex>", moduleIdentifier, mId.URL(), TarballChecksumSeparator)
+	}
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		return nil, fmt.Errorf("%s: invalid sha256 checksum %q", moduleIdentifier, checksum)
+	}
+
+	// tarballs are content addressed, a cached checksum never needs a download
+	targetDir := filepath.Join(f.moduleCacheDir, tarballCacheDir, checksum)
+	if !utils.DirExists(targetDir) {
+		if err := f.download(ctx, mId.URL(), checksum, targetDir); err != nil {
+			return nil, err
+		}
+	}
+	return modules.NewModuleFromFS(moduleIdentifier, utils.DirFS(targetDir))
+}
+
+func (f *tarballResolver) download(ctx context.Context, url, checksum, targetDir string) error {
+	if err := os.MkdirAll(filepath.Dir(targetDir), 0755); err != nil {
+		return err
+	}
+	tarFile, err := os.CreateTemp(filepath.Dir(targetDir), ".tar-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tarFile.Name())
+	defer tarFile.Close()
+
+	log.Printf("Downloading: %s", url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tarFile, hash), io.LimitReader(resp.Body, modzip.MaxZipFile+1))
+	if err != nil {
+		return err
+	}
+	if size > modzip.MaxZipFile {
+		return fmt.Errorf("%s: tarball larger than %d bytes", url, modzip.MaxZipFile)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != checksum {
+		return fmt.Errorf("%s: checksum mismatch: downloaded sha256=%s, expected sha256=%s", url, sum, checksum)
+	}
+
+	if _, err := tarFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := extractTarball(tarFile, targetDir); err != nil {
+		return fmt.Errorf("invalid tarball %s: %w", url, err)
+	}
+	log.Println("Module downloaded", url)
+	return nil
+}
+
+// extractTarball extracts the regular files of a tar archive, gzip
+// compressed or not, into targetDir. A single top-level directory shared by
+// every entry, as in most release tarballs, is stripped.
+func extractTarball(r io.ReadSeeker, targetDir string) error {
+	files, err := readTarball(r)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	prefix := commonTopLevelDir(files)
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(targetDir), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return err
+	}
+
+	tr, err := newTarReader(r)
+	if err != nil {
+		return err
+	}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(tarEntryName(header), prefix)
+		if err := extractTarFile(tr, filepath.Join(tmpDir, filepath.FromSlash(name))); err != nil {
+			return err
+		}
+	}
+
+	os.RemoveAll(targetDir) // ensure target dir is clean
+	return os.Rename(tmpDir, targetDir)
+}
+
+// readTarball checks the entries of a tar archive and returns the names of
+// its regular files. Links and special files are rejected, as in module
+// archives.
+func readTarball(r io.ReadSeeker) ([]string, error) {
+	tr, err := newTarReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	seen := make(map[string]bool)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		name := tarEntryName(header)
+		switch header.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeReg:
+		default:
+			return nil, fmt.Errorf("%s: %w: not a regular file", header.Name, modzip.ErrInvalidFile)
+		}
+		if err := modzip.CheckFilePath(name); err != nil {
+			return nil, err
+		}
+		if header.Size > modzip.MaxFileSize {
+			return nil, fmt.Errorf("%s: %w: larger than %d bytes", header.Name, modzip.ErrInvalidFile, modzip.MaxFileSize)
+		}
+		// entries colliding on case-insensitive file systems would
+		// silently overwrite each other
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("%s: %w: duplicate entry", header.Name, modzip.ErrInvalidFile)
+		}
+		seen[strings.ToLower(name)] = true
+		files = append(files, name)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("tarball has no files")
+	}
+	return files, nil
+}
+
+// commonTopLevelDir returns "<dir>/" if every file is under the same
+// top-level directory, or "" otherwise.
+func commonTopLevelDir(files []string) string {
+	dir, _, found := strings.Cut(files[0], "/")
+	if !found {
+		return ""
+	}
+	for _, name := range files[1:] {
+		if !strings.HasPrefix(name, dir+"/") {
+			return ""
+		}
+	}
+	return dir + "/"
+}
+
+// tarEntryName returns the name of a tar entry without a leading "./" or
+// trailing "/".
+func tarEntryName(header *tar.Header) string {
+	return strings.TrimSuffix(strings.TrimPrefix(header.Name, "./"), "/")
+}
+
+// newTarReader detects gzip compression from the magic number.
+func newTarReader(r io.ReadSeeker) (*tar.Reader, error) {
+	magic := make([]byte, 2)
+	n, _ := io.ReadFull(r, magic)
+	if _, err := r.Seek(-int64(n), io.SeekCurrent); err != nil {
+		return nil, err
+	}
+	if n == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return tar.NewReader(gz), nil
+	}
+	return tar.NewReader(r), nil
+}
+
+func extractTarFile(r io.Reader, targetPath string) error {
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, io.LimitReader(r, modzip.MaxFileSize)); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// NewTarballResolver returns a resolver downloading tarball modules into the
+// module cache in targetDir.
+func NewTarballResolver(targetDir string) custodian.Resolver {
+	return &tarballResolver{
+		client:         http.DefaultClient,
+		moduleCacheDir: targetDir,
+	}
+}
@@ -0,0 +1,189 @@
+package resolvers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type tarEntry struct {
+	name     string
+	content  string
+	typeflag byte
+}
+
+func buildTarball(t *testing.T, compress bool, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: entry.typeflag}
+		if entry.typeflag == 0 {
+			header.Typeflag = tar.TypeReg
+		}
+		if header.Typeflag != tar.TypeReg {
+			header.Size = 0
+			header.Linkname = entry.content
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("WriteHeader() failed: %v", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			tw.Write([]byte(entry.content))
+		}
+	}
+	tw.Close()
+	if gz != nil {
+		gz.Close()
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func Test_tarballResolver_Resolve(t *testing.T) {
+	tarballs := map[string][]byte{
+		"/lib-1.2.3.tar.gz": buildTarball(t, true,
+			tarEntry{name: "lib-1.2.3/", typeflag: tar.TypeDir},
+			tarEntry{name: "lib-1.2.3/custodian.json", content: `{"module": "example.com/lib", "require": {}}`},
+			tarEntry{name: "lib-1.2.3/main.libsonnet", content: "{ a: 1 }"},
+		),
+		"/flat.tar": buildTarball(t, false,
+			tarEntry{name: "./main.libsonnet", content: "{ a: 1 }"},
+			tarEntry{name: "./sub/other.libsonnet", content: "{ b: 2 }"},
+		),
+		"/escape.tar.gz": buildTarball(t, true,
+			tarEntry{name: "../evil.libsonnet", content: "{}"},
+		),
+		"/symlink.tar.gz": buildTarball(t, true,
+			tarEntry{name: "main.libsonnet", content: "{}"},
+			tarEntry{name: "passwd", content: "/etc/passwd", typeflag: tar.TypeSymlink},
+		),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, exists := tarballs[r.URL.Path]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	resolver := NewTarballResolver(t.TempDir())
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		wantFiles        []string
+		wantErr          bool
+	}{
+		{
+			name:             "gzip tarball with top-level directory",
+			moduleIdentifier: server.URL + "/lib-1.2.3.tar.gz#sha256=" + sha256Hex(tarballs["/lib-1.2.3.tar.gz"]),
+			wantFiles:        []string{"custodian.json", "main.libsonnet"},
+		},
+		{
+			name:             "uncompressed tarball without top-level directory",
+			moduleIdentifier: server.URL + "/flat.tar#sha256=" + sha256Hex(tarballs["/flat.tar"]),
+			wantFiles:        []string{"main.libsonnet", "sub/other.libsonnet"},
+		},
+		{
+			name:             "missing checksum",
+			moduleIdentifier: server.URL + "/lib-1.2.3.tar.gz",
+			wantErr:          true,
+		},
+		{
+			name:             "checksum mismatch",
+			moduleIdentifier: server.URL + "/lib-1.2.3.tar.gz#sha256=" + sha256Hex([]byte("other")),
+			wantErr:          true,
+		},
+		{
+			name:             "path escaping the module",
+			moduleIdentifier: server.URL + "/escape.tar.gz#sha256=" + sha256Hex(tarballs["/escape.tar.gz"]),
+			wantErr:          true,
+		},
+		{
+			name:             "symlink",
+			moduleIdentifier: server.URL + "/symlink.tar.gz#sha256=" + sha256Hex(tarballs["/symlink.tar.gz"]),
+			wantErr:          true,
+		},
+		{
+			name:             "not found",
+			moduleIdentifier: server.URL + "/missing.tar.gz#sha256=" + sha256Hex(nil),
+			wantErr:          true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := resolver.Resolve(context.Background(), tt.moduleIdentifier)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("Resolve() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("Resolve() succeeded unexpectedly")
+			}
+			if got.Identifier() != tt.moduleIdentifier {
+				t.Errorf("Resolve() = %v, want %v", got.Identifier(), tt.moduleIdentifier)
+			}
+			for _, name := range tt.wantFiles {
+				if _, err := fs.Stat(got.FileSystem(), name); err != nil {
+					t.Errorf("module file %s: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTarballModuleIdentifier_Name(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		want             string
+	}{
+		{
+			name:             "versioned tarball",
+			moduleIdentifier: "https://example.com/releases/lib-1.2.3.tar.gz#sha256=00",
+			want:             "lib",
+		},
+		{
+			name:             "v prefixed pre-release",
+			moduleIdentifier: "https://example.com/jsonnet-libs-v2.0.0-rc.1.tgz",
+			want:             "jsonnet-libs",
+		},
+		{
+			name:             "unversioned tarball with query",
+			moduleIdentifier: "https://example.com/download/lib.tar?token=1#sha256=00",
+			want:             "lib",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TarballModuleIdentifier(tt.moduleIdentifier).Name()
+			if got != tt.want {
+				t.Errorf("Name() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
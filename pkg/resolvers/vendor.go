@@ -0,0 +1,55 @@
+package resolvers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+)
+
+// WithVendorDir makes the resolver load the non-local modules archived in
+// dir, as written by custodian mod vendor, instead of fetching them.
+func WithVendorDir(dir string) Option {
+	return func(f *chainResolver) {
+		f.vendorDir = dir
+	}
+}
+
+// VendorArchivePath returns the archive of moduleIdentifier in vendorDir.
+// Identifiers are escaped to a single file name.
+func VendorArchivePath(vendorDir, moduleIdentifier string) string {
+	return filepath.Join(vendorDir, url.PathEscape(moduleIdentifier)+".zip")
+}
+
+// resolveVendored returns the module archived in the vendor directory, and
+// whether there is one. Archives are opened in place, without extraction.
+func (f *chainResolver) resolveVendored(moduleIdentifier string) (custodian.Module, bool, error) {
+	if f.vendorDir == "" {
+		return nil, false, nil
+	}
+	archive, err := os.Open(VendorArchivePath(f.vendorDir, moduleIdentifier))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, true, err
+	}
+	// the archive is read as long as the module is used
+	info, err := archive.Stat()
+	if err != nil {
+		archive.Close()
+		return nil, true, err
+	}
+	fsys, err := modzip.OpenFS(archive, info.Size(), moduleIdentifier)
+	if err != nil {
+		archive.Close()
+		return nil, true, fmt.Errorf("invalid vendored archive %s: %w", archive.Name(), err)
+	}
+	module, err := modules.NewModuleFromFS(moduleIdentifier, fsys)
+	return module, true, err
+}
@@ -0,0 +1,92 @@
+package resolvers
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+)
+
+func Test_chainResolver_Resolve_vendorDir(t *testing.T) {
+	vendorDir := t.TempDir()
+	vendor := func(archivedIdentifier, moduleIdentifier string) {
+		archive, err := os.Create(VendorArchivePath(vendorDir, moduleIdentifier))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer archive.Close()
+		fsys := fstest.MapFS{"lib.libsonnet": {Data: []byte("{}")}}
+		if err := modzip.Create(archive, archivedIdentifier, fsys); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vendor("github.com/org/lib@v1.0.0", "github.com/org/lib@v1.0.0")
+	vendor("oci://registry.example.com/org/lib:v1", "oci://registry.example.com/org/lib:v1")
+	vendor("github.com/org/tampered@v1.0.0", "github.com/org/tampered@v1.0.0")
+	vendor("github.com/org/other@v1.0.0", "github.com/org/renamed@v1.0.0")
+
+	resolver := &chainResolver{
+		localResolver:  &stubResolver{},
+		ociResolver:    &stubResolver{},
+		remoteResolver: &stubResolver{},
+		vendorDir:      vendorDir,
+		lockFile: &modules.LockFile{Modules: []modules.LockEntry{
+			{Module: "github.com/org/tampered@v1.0.0", Hash: "h1:tampered"},
+		}},
+	}
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		moduleIdentifier string
+		wantVendored     bool
+		wantErr          bool
+	}{
+		{
+			name:             "vendored remote",
+			moduleIdentifier: "github.com/org/lib@v1.0.0",
+			wantVendored:     true,
+		},
+		{
+			name:             "vendored OCI artifact",
+			moduleIdentifier: "oci://registry.example.com/org/lib:v1",
+			wantVendored:     true,
+		},
+		{
+			name:             "module not vendored",
+			moduleIdentifier: "github.com/org/lib@v1.1.0",
+		},
+		{
+			name:             "vendored archive not matching the lock file",
+			moduleIdentifier: "github.com/org/tampered@v1.0.0",
+			wantErr:          true,
+		},
+		{
+			name:             "archive of another module",
+			moduleIdentifier: "github.com/org/renamed@v1.0.0",
+			wantErr:          true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := resolver.Resolve(context.Background(), tt.moduleIdentifier)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("Resolve() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("Resolve() succeeded unexpectedly")
+			}
+			_, err := fs.Stat(got.FileSystem(), "lib.libsonnet")
+			if vendored := err == nil; vendored != tt.wantVendored {
+				t.Errorf("Resolve() vendored = %v, want %v", vendored, tt.wantVendored)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package sumdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/sumdb/note"
+	"golang.org/x/mod/sumdb/tlog"
+)
+
+// Client looks up module hashes in a checksum database, verifying that every
+// record is in a tree head signed by the database and that every tree head
+// extends the ones seen before.
+type Client struct {
+	baseURL    string
+	verifiers  note.Verifiers
+	httpClient *http.Client
+
+	mu   sync.Mutex
+	tree tlog.Tree // largest verified tree head
+	// latestPath keeps the signed tree head of tree across runs.
+	latestPath string
+}
+
+// NewClient returns a client of the checksum database at baseURL signing its
+// tree heads with the key of verifierKey, as printed by custodian sumdb. The
+// largest verified tree head is kept in the user cache directory, under
+// custodian/sumdb/<name>/latest, so that every run checks the database
+// against the tree heads of the previous ones.
+func NewClient(baseURL, verifierKey string) (*Client, error) {
+	verifier, err := note.NewVerifier(verifierKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum database key: %w", err)
+	}
+	client := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		verifiers:  note.VerifierList(verifier),
+		httpClient: http.DefaultClient,
+	}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		client.latestPath = filepath.Join(cacheDir, "custodian", "sumdb", url.PathEscape(verifier.Name()), "latest")
+		if err := client.loadLatest(); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// Lookup returns the hash recorded for module.
+func (c *Client) Lookup(ctx context.Context, module string) (string, error) {
+	response := &LookupResponse{}
+	if err := c.getJSON(ctx, "/lookup/"+url.PathEscape(module), response); err != nil {
+		return "", fmt.Errorf("%s: %w", module, err)
+	}
+	tree, err := c.verifyTree(ctx, []byte(response.Tree))
+	if err != nil {
+		return "", err
+	}
+	record := response.Record
+	if record.Module != module {
+		return "", fmt.Errorf("%s: checksum database returned the record of %s", module, record.Module)
+	}
+	if err := tlog.CheckRecord(response.Proof, tree.N, tree.Hash, record.ID, tlog.RecordHash(record.text())); err != nil {
+		return "", fmt.Errorf("%s: invalid checksum database record: %w", module, err)
+	}
+	return record.Hash, nil
+}
+
+// openTree checks the signature of a tree head and parses it.
+func (c *Client) openTree(signedTree []byte) (tlog.Tree, error) {
+	n, err := note.Open(signedTree, c.verifiers)
+	if err != nil {
+		return tlog.Tree{}, fmt.Errorf("invalid checksum database tree: %w", err)
+	}
+	tree, err := tlog.ParseTree([]byte(n.Text))
+	if err != nil {
+		return tlog.Tree{}, fmt.Errorf("invalid checksum database tree: %w", err)
+	}
+	return tree, nil
+}
+
+// verifyTree checks the signature of a tree head and its consistency with
+// the largest tree head seen so far, which it replaces if larger.
+func (c *Client) verifyTree(ctx context.Context, signedTree []byte) (tlog.Tree, error) {
+	tree, err := c.openTree(signedTree)
+	if err != nil {
+		return tlog.Tree{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	older, newer := c.tree, tree
+	if older.N > newer.N {
+		older, newer = newer, older
+	}
+	switch {
+	case older.N == 0:
+	case older.N == newer.N:
+		if older.Hash != newer.Hash {
+			return tlog.Tree{}, fmt.Errorf("checksum database served two trees of size %d", tree.N)
+		}
+	default:
+		var proof tlog.TreeProof
+		if err := c.getJSON(ctx, fmt.Sprintf("/proof/%d/%d", older.N, newer.N), &proof); err != nil {
+			return tlog.Tree{}, err
+		}
+		if err := tlog.CheckTree(proof, newer.N, newer.Hash, older.N, older.Hash); err != nil {
+			return tlog.Tree{}, fmt.Errorf("checksum database tree of size %d does not extend tree of size %d: %w", newer.N, older.N, err)
+		}
+	}
+	if tree.N > c.tree.N {
+		if err := c.storeLatest(signedTree, tree); err != nil {
+			return tlog.Tree{}, fmt.Errorf("failed to save checksum database tree: %w", err)
+		}
+		c.tree = tree
+	}
+	return tree, nil
+}
+
+// loadLatest reads the tree head saved by a previous run, if any.
+func (c *Client) loadLatest() error {
+	signedTree, err := os.ReadFile(c.latestPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	tree, err := c.openTree(signedTree)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.latestPath, err)
+	}
+	c.tree = tree
+	return nil
+}
+
+// storeLatest saves signedTree, the signed tree head of tree, unless a
+// concurrent run saved a larger one.
+func (c *Client) storeLatest(signedTree []byte, tree tlog.Tree) error {
+	if c.latestPath == "" {
+		return nil
+	}
+	if saved, err := os.ReadFile(c.latestPath); err == nil {
+		if savedTree, err := c.openTree(saved); err == nil && savedTree.N >= tree.N {
+			return nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(c.latestPath), 0755); err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(c.latestPath), ".latest-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(signedTree)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), c.latestPath)
+}
+
+func (c *Client) getJSON(ctx context.Context, requestPath string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+requestPath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusBadRequest {
+			return fmt.Errorf("%w: %s", ErrNotFound, strings.TrimSpace(string(message)))
+		}
+		return fmt.Errorf("checksum database: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,179 @@
+// Package sumdb implements a checksum database: an append-only transparency
+// log of the first hash seen for each module, signed by its server, and a
+// client verifying the hashes it serves against the signed log.
+package sumdb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/sumdb/note"
+	"golang.org/x/mod/sumdb/tlog"
+)
+
+const (
+	recordsFileName = "records"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrNotFound         = errors.New("not found in checksum database")
+)
+
+// Record is a module and the hash of its content, as computed by
+// modzip.HashFS, at position ID in the log.
+type Record struct {
+	ID     int64  `json:"id"`
+	Module string `json:"module"`
+	Hash   string `json:"hash"`
+}
+
+// text returns the record data hashed into the log.
+func (r Record) text() []byte {
+	return []byte(r.Module + " " + r.Hash + "\n")
+}
+
+// Log is an append-only log of records stored in a directory, one record per
+// line of its records file.
+type Log struct {
+	mu      sync.Mutex
+	file    *os.File
+	signer  note.Signer
+	records []Record
+	index   map[string]int64 // module -> record id
+	hashes  []tlog.Hash      // stored hashes of the tree, see tlog.StoredHashIndex
+}
+
+// OpenLog opens the log in dir, creating it if needed, signing its tree heads
+// with signer.
+func OpenLog(dir string, signer note.Signer) (*Log, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, recordsFileName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{file: file, signer: signer, index: make(map[string]int64)}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		module, hash, found := strings.Cut(scanner.Text(), " ")
+		if !found {
+			file.Close()
+			return nil, fmt.Errorf("invalid record %d in %s", len(l.records), file.Name())
+		}
+		if err := l.append(module, hash); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+// readHashes reads the stored hashes of the tree, as a tlog.HashReader.
+func (l *Log) readHashes(indexes []int64) ([]tlog.Hash, error) {
+	hashes := make([]tlog.Hash, len(indexes))
+	for i, index := range indexes {
+		if index < 0 || index >= int64(len(l.hashes)) {
+			return nil, fmt.Errorf("hash %d not stored", index)
+		}
+		hashes[i] = l.hashes[index]
+	}
+	return hashes, nil
+}
+
+// append adds a record in memory.
+func (l *Log) append(module, hash string) error {
+	record := Record{ID: int64(len(l.records)), Module: module, Hash: hash}
+	if _, exists := l.index[module]; exists {
+		return fmt.Errorf("duplicate record of %s", module)
+	}
+	hashes, err := tlog.StoredHashes(record.ID, record.text(), tlog.HashReaderFunc(l.readHashes))
+	if err != nil {
+		return err
+	}
+	l.hashes = append(l.hashes, hashes...)
+	l.records = append(l.records, record)
+	l.index[module] = record.ID
+	return nil
+}
+
+// Lookup returns the record of module, if any.
+func (l *Log) Lookup(module string) (Record, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id, exists := l.index[module]
+	if !exists {
+		return Record{}, false
+	}
+	return l.records[id], true
+}
+
+// Add appends the record of module unless the log has one already, and
+// returns the record of the log: hashes seen first are never replaced.
+func (l *Log) Add(module, hash string) (Record, error) {
+	if strings.ContainsAny(module+hash, " \n") || module == "" || hash == "" {
+		return Record{}, fmt.Errorf("invalid record %q %q", module, hash)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if id, exists := l.index[module]; exists {
+		return l.records[id], nil
+	}
+	if _, err := fmt.Fprintf(l.file, "%s %s\n", module, hash); err != nil {
+		return Record{}, err
+	}
+	if err := l.file.Sync(); err != nil {
+		return Record{}, err
+	}
+	if err := l.append(module, hash); err != nil {
+		return Record{}, err
+	}
+	return l.records[len(l.records)-1], nil
+}
+
+// SignedTree returns the signed note of the current tree head, with the
+// size of the tree.
+func (l *Log) SignedTree() ([]byte, int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := int64(len(l.records))
+	hash, err := tlog.TreeHash(n, tlog.HashReaderFunc(l.readHashes))
+	if err != nil {
+		return nil, 0, err
+	}
+	signed, err := note.Sign(&note.Note{Text: string(tlog.FormatTree(tlog.Tree{N: n, Hash: hash}))}, l.signer)
+	return signed, n, err
+}
+
+// ProveRecord returns the proof that record id is in the tree of size n.
+func (l *Log) ProveRecord(id, n int64) (tlog.RecordProof, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return tlog.ProveRecord(n, id, tlog.HashReaderFunc(l.readHashes))
+}
+
+// ProveTree returns the proof that the tree of size n is a prefix of the tree
+// of size t.
+func (l *Log) ProveTree(t, n int64) (tlog.TreeProof, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t > int64(len(l.records)) {
+		return nil, fmt.Errorf("tree %d is larger than the log", t)
+	}
+	return tlog.ProveTree(t, n, tlog.HashReaderFunc(l.readHashes))
+}
@@ -0,0 +1,121 @@
+package sumdb
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/utils"
+
+	"golang.org/x/mod/sumdb/tlog"
+)
+
+// LookupResponse is the record of a module with the signed tree head it was
+// proven against.
+type LookupResponse struct {
+	Record Record `json:"record"`
+	// Proof is the proof that Record is in the tree of Tree.
+	Proof tlog.RecordProof `json:"proof"`
+	// Tree is the signed note of the tree head.
+	Tree string `json:"tree"`
+}
+
+type server struct {
+	log      *Log
+	resolver custodian.Resolver
+}
+
+// NewHandler returns an http.Handler serving the checksum database of log,
+// recording the modules of resolver the first time they are looked up:
+//
+//	GET /latest               signed note of the tree head
+//	GET /lookup/<module>      LookupResponse of module
+//	GET /proof/<old>/<new>    tlog.TreeProof of tree old in tree new
+func NewHandler(log *Log, resolver custodian.Resolver) http.Handler {
+	return &server{log: log, resolver: resolver}
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	requestPath := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case requestPath == "latest":
+		tree, _, err := s.log.SignedTree()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(tree)
+	case strings.HasPrefix(requestPath, "lookup/"):
+		s.serveLookup(w, r, strings.TrimPrefix(requestPath, "lookup/"))
+	case strings.HasPrefix(requestPath, "proof/"):
+		oldSize, newSize, _ := strings.Cut(strings.TrimPrefix(requestPath, "proof/"), "/")
+		old, err1 := strconv.ParseInt(oldSize, 10, 64)
+		n, err2 := strconv.ParseInt(newSize, 10, 64)
+		if err1 != nil || err2 != nil {
+			http.Error(w, "invalid tree sizes", http.StatusBadRequest)
+			return
+		}
+		proof, err := s.log.ProveTree(n, old)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(proof)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *server) serveLookup(w http.ResponseWriter, r *http.Request, module string) {
+	record, exists := s.log.Lookup(module)
+	if !exists {
+		// paths and URLs name content the server cannot vouch for
+		if utils.IsLocalPath(module) || strings.Contains(module, "://") || !strings.Contains(module, "@") {
+			http.Error(w, "only versioned git modules are recorded", http.StatusBadRequest)
+			return
+		}
+		resolved, err := s.resolver.Resolve(r.Context(), module)
+		if err != nil {
+			log.Printf("Failed to resolve %s: %v", module, err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if resolved.Identifier() != module {
+			http.Error(w, "not a canonical identifier, look up "+resolved.Identifier(), http.StatusNotFound)
+			return
+		}
+		hash, err := modzip.HashFS(module, resolved.FileSystem())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if record, err = s.log.Add(module, hash); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Recorded %s %s", record.Module, record.Hash)
+	}
+
+	tree, n, err := s.log.SignedTree()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proof, err := s.log.ProveRecord(record.ID, n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LookupResponse{Record: record, Proof: proof, Tree: string(tree)})
+}
@@ -0,0 +1,206 @@
+package sumdb
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/custodian"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modules"
+	"github.com/git-justanotherone/jsonnet-custodian/pkg/modzip"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// mapResolver resolves the modules of its map, by identifier.
+type mapResolver map[string]fstest.MapFS
+
+func (m mapResolver) Resolve(ctx context.Context, moduleIdentifier string) (custodian.Module, error) {
+	moduleFS, exists := m[moduleIdentifier]
+	if !exists {
+		return nil, fmt.Errorf("%s: unknown module", moduleIdentifier)
+	}
+	return modules.NewModuleFromFS(moduleIdentifier, moduleFS)
+}
+
+func newTestKey(t *testing.T, name string) (note.Signer, string) {
+	t.Helper()
+	skey, vkey, err := note.GenerateKey(rand.Reader, name)
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %v", err)
+	}
+	signer, err := note.NewSigner(skey)
+	if err != nil {
+		t.Fatalf("NewSigner() failed: %v", err)
+	}
+	return signer, vkey
+}
+
+func TestClient_Lookup(t *testing.T) {
+	// clients keep the tree heads they verified in the user cache
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	signer, vkey := newTestKey(t, "sumdb.example.com")
+	_, otherVkey := newTestKey(t, "sumdb.example.com")
+	dir := t.TempDir()
+	checksumLog, err := OpenLog(dir, signer)
+	if err != nil {
+		t.Fatalf("OpenLog() failed: %v", err)
+	}
+	resolver := mapResolver{
+		"github.com/org/lib@v1.0.0": {"main.libsonnet": {Data: []byte("{ v: 1 }")}},
+		"github.com/org/lib@v1.1.0": {"main.libsonnet": {Data: []byte("{ v: 2 }")}},
+		"github.com/org/app@v1.0.0": {"main.libsonnet": {Data: []byte("{}")}},
+	}
+	server := httptest.NewServer(NewHandler(checksumLog, resolver))
+	t.Cleanup(server.Close)
+	client, err := NewClient(server.URL, vkey)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+
+	wantHash := func(module string) string {
+		hash, err := modzip.HashFS(module, resolver[module])
+		if err != nil {
+			t.Fatalf("HashFS() failed: %v", err)
+		}
+		return hash
+	}
+	v1Hash := wantHash("github.com/org/lib@v1.0.0")
+
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		module  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "first lookup records the module",
+			module: "github.com/org/lib@v1.0.0",
+			want:   v1Hash,
+		},
+		{
+			name:   "second module grows the tree",
+			module: "github.com/org/lib@v1.1.0",
+			want:   wantHash("github.com/org/lib@v1.1.0"),
+		},
+		{
+			name:   "recorded module",
+			module: "github.com/org/lib@v1.0.0",
+			want:   v1Hash,
+		},
+		{
+			name:    "unknown module",
+			module:  "github.com/org/missing@v1.0.0",
+			wantErr: true,
+		},
+		{
+			name:    "unversioned module",
+			module:  "github.com/org/lib",
+			wantErr: true,
+		},
+		{
+			name:    "local path",
+			module:  "../lib@v1.0.0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := client.Lookup(context.Background(), tt.module)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("Lookup() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("Lookup() succeeded unexpectedly")
+			}
+			if got != tt.want {
+				t.Errorf("Lookup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// the first hash seen is kept when the content changes
+	resolver["github.com/org/lib@v1.0.0"] = fstest.MapFS{"main.libsonnet": {Data: []byte("{ v: 3 }")}}
+	if got, err := client.Lookup(context.Background(), "github.com/org/lib@v1.0.0"); err != nil || got != v1Hash {
+		t.Errorf("Lookup() after change = %v, %v, want %v", got, err, v1Hash)
+	}
+
+	// records survive a restart
+	checksumLog.Close()
+	reopened, err := OpenLog(dir, signer)
+	if err != nil {
+		t.Fatalf("OpenLog() failed: %v", err)
+	}
+	defer reopened.Close()
+	if record, exists := reopened.Lookup("github.com/org/lib@v1.0.0"); !exists || record.Hash != v1Hash {
+		t.Errorf("Lookup() after reopen = %v, %v, want %v", record, exists, v1Hash)
+	}
+
+	// trees signed by another key are refused
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	otherClient, err := NewClient(server.URL, otherVkey)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	if _, err := otherClient.Lookup(context.Background(), "github.com/org/lib@v1.0.0"); err == nil {
+		t.Error("Lookup() with another key succeeded unexpectedly")
+	}
+}
+
+func TestClient_Lookup_forkedLog(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	signer, vkey := newTestKey(t, "sumdb.example.com")
+	resolver := mapResolver{
+		"github.com/org/lib@v1.0.0": {"main.libsonnet": {Data: []byte("{ v: 1 }")}},
+		"github.com/org/app@v1.0.0": {"main.libsonnet": {Data: []byte("{}")}},
+	}
+	checksumLog, err := OpenLog(t.TempDir(), signer)
+	if err != nil {
+		t.Fatalf("OpenLog() failed: %v", err)
+	}
+	defer checksumLog.Close()
+	// a log rewriting its history, signed with the same key
+	forkedLog, err := OpenLog(t.TempDir(), signer)
+	if err != nil {
+		t.Fatalf("OpenLog() failed: %v", err)
+	}
+	defer forkedLog.Close()
+	forkedLog.Add("github.com/org/lib@v1.0.0", "h1:forged")
+
+	server := httptest.NewServer(NewHandler(checksumLog, resolver))
+	t.Cleanup(server.Close)
+	forkedServer := httptest.NewServer(NewHandler(forkedLog, resolver))
+	t.Cleanup(forkedServer.Close)
+
+	client, err := NewClient(server.URL, vkey)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	if _, err := client.Lookup(context.Background(), "github.com/org/lib@v1.0.0"); err != nil {
+		t.Fatalf("Lookup() failed: %v", err)
+	}
+	client.baseURL = forkedServer.URL
+	if _, err := client.Lookup(context.Background(), "github.com/org/app@v1.0.0"); err == nil {
+		t.Error("Lookup() on a forked log succeeded unexpectedly")
+	}
+
+	// the tree head verified by the first client is kept for the next runs
+	nextClient, err := NewClient(forkedServer.URL, vkey)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	if _, err := nextClient.Lookup(context.Background(), "github.com/org/app@v1.0.0"); err == nil {
+		t.Error("Lookup() on a forked log in a later run succeeded unexpectedly")
+	}
+	if _, err := client.Lookup(context.Background(), "github.com/org/missing@v1.0.0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup() of an unknown module = %v, want %v", err, ErrNotFound)
+	}
+}
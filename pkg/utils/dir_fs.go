@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrEscapesRoot is returned for paths leading outside of the directory of a
+// module, through .. elements, absolute paths or symlinks.
+var ErrEscapesRoot = errors.New("path escapes module root")
+
+// maxSymlinks bounds the symlinks followed by escapes, as loops are
+// reported by os.Root anyway.
+const maxSymlinks = 40
+
+type rootFS string
+
+// DirFS returns a file system for the tree of files rooted at dir, like
+// os.DirFS, except that symlinks are only followed within dir.
+func DirFS(dir string) fs.FS {
+	return rootFS(dir)
+}
+
+func (dir rootFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	file, err := os.OpenInRoot(string(dir), name)
+	if err != nil {
+		if dir.escapes(name) {
+			err = ErrEscapesRoot
+		} else if pathErr, ok := err.(*fs.PathError); ok {
+			err = pathErr.Err
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return file, nil
+}
+
+// escapes reports whether name leads outside of dir, resolving its symlinks
+// like os.Root: absolute symlinks always escape, even dangling ones. The os
+// package does not export the error of os.Root for these paths.
+func (dir rootFS) escapes(name string) bool {
+	root, err := filepath.EvalSymlinks(string(dir))
+	if err != nil {
+		return false
+	}
+	current, pending := root, strings.Split(name, "/")
+	for links := 0; len(pending) > 0; {
+		next := filepath.Join(current, pending[0])
+		pending = pending[1:]
+		if rel, err := filepath.Rel(root, next); err != nil || !filepath.IsLocal(rel) {
+			return true
+		}
+		info, err := os.Lstat(next)
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return false
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return false
+		}
+		if filepath.IsAbs(target) {
+			return true
+		}
+		pending = append(strings.Split(filepath.ToSlash(target), "/"), pending...)
+	}
+	return false
+}
+
+// FSDir returns the directory of a file system returned by DirFS.
+func FSDir(fsys fs.FS) (string, bool) {
+	dir, ok := fsys.(rootFS)
+	return string(dir), ok
+}
+
+// ReadLink returns the destination of the symlink name, for modules hashing
+// their symlinks.
+func (dir rootFS) ReadLink(name string) (string, error) {
+	root, err := os.OpenRoot(string(dir))
+	if err != nil {
+		return "", err
+	}
+	defer root.Close()
+	return root.Readlink(name)
+}
+
+func (dir rootFS) Lstat(name string) (fs.FileInfo, error) {
+	root, err := os.OpenRoot(string(dir))
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	return root.Lstat(name)
+}
@@ -29,6 +29,20 @@ func BuildFoundAtPath(moduleIdentifier, filePath string) string {
 	return moduleIdentifier + ModuleIdentifierSeparator + filePath
 }
 
+// DisplayFoundAtPath returns the location of foundAt as read by users: the
+// path in the module after its identifier, or the bare path for the root
+// module.
+func DisplayFoundAtPath(foundAt string) string {
+	if !strings.Contains(foundAt, ModuleIdentifierSeparator) {
+		return foundAt
+	}
+	moduleIdentifier, filePath := ParseImportedFrom(foundAt)
+	if moduleIdentifier == "" || moduleIdentifier == "." {
+		return filePath
+	}
+	return strings.TrimSuffix(moduleIdentifier, "/") + "/" + filePath
+}
+
 func ParseImportedPath(importedPath string) (repository string, filepath string) {
 	split := strings.SplitN(importedPath, "/", 2)
 	if len(split) < 2 {
@@ -66,3 +80,14 @@ func DirExists(path string) bool {
 func IsLocalPath(path string) bool {
 	return strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") || strings.HasPrefix(path, "/") || path == "." || path == ".."
 }
+
+// IsVersionedLocalPath reports whether path is a local path followed by a
+// version, such as ../lib@v1.2.0, which identifies a git repository on disk
+// rather than a plain directory.
+func IsVersionedLocalPath(path string) bool {
+	if !IsLocalPath(path) {
+		return false
+	}
+	separator := strings.LastIndex(path, "@")
+	return separator >= 0 && separator < len(path)-1 && !strings.Contains(path[separator:], "/")
+}
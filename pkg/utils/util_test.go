@@ -84,6 +84,49 @@ func TestParseImportedFrom(t *testing.T) {
 	}
 }
 
+func TestDisplayFoundAtPath(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		foundAt string
+		want    string
+	}{
+		{
+			name:    "dependency module",
+			foundAt: "github.com/org/lib@v1.2.0" + ModuleIdentifierSeparator + "path.libsonnet",
+			want:    "github.com/org/lib@v1.2.0/path.libsonnet",
+		},
+		{
+			name:    "root module",
+			foundAt: "." + ModuleIdentifierSeparator + "lib/file.libsonnet",
+			want:    "lib/file.libsonnet",
+		},
+		{
+			name:    "tarball module",
+			foundAt: "https://example.com/lib.tar.gz" + ModuleIdentifierSeparator + "main.jsonnet",
+			want:    "https://example.com/lib.tar.gz/main.jsonnet",
+		},
+		{
+			name:    "library path with trailing slash",
+			foundAt: "vendor/" + ModuleIdentifierSeparator + "lib.libsonnet",
+			want:    "vendor/lib.libsonnet",
+		},
+		{
+			name:    "not a module location",
+			foundAt: "main.jsonnet",
+			want:    "main.jsonnet",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DisplayFoundAtPath(tt.foundAt)
+			if got != tt.want {
+				t.Errorf("DisplayFoundAtPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseImportedPath(t *testing.T) {
 	tests := []struct {
 		name string // description of this test case
@@ -118,3 +161,46 @@ func TestParseImportedPath(t *testing.T) {
 		})
 	}
 }
+
+func TestIsVersionedLocalPath(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		path string
+		want bool
+	}{
+		{
+			name: "relative path with version",
+			path: "../lib@v1.2.0",
+			want: true,
+		},
+		{
+			name: "absolute path with commit",
+			path: "/srv/git/lib@0123456789ab",
+			want: true,
+		},
+		{
+			name: "plain directory",
+			path: "./lib",
+			want: false,
+		},
+		{
+			name: "directory containing @",
+			path: "./node_modules/@scope/lib",
+			want: false,
+		},
+		{
+			name: "remote identifier",
+			path: "github.com/org/lib@v1.2.0",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IsVersionedLocalPath(tt.path)
+			if got != tt.want {
+				t.Errorf("IsVersionedLocalPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}